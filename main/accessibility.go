@@ -0,0 +1,192 @@
+// main package
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// minContrastRatio is the WCAG AA contrast ratio required for normal text.
+const minContrastRatio = 4.5
+
+// markerSet holds the markers drawn around quotes, reactions, replies, code blocks and the unread divider,
+// and the delivery states of own messages.
+type markerSet struct {
+	quote     string
+	reactions string
+	replies   string
+	codeStart string
+	codeLine  string
+	codeEnd   string
+	unread    string
+	dayStart  string
+	dayEnd    string
+	pending   string
+	delivered string
+	failed    string
+}
+
+var (
+	boxMarkers = markerSet{
+		quote:     "┌ ",
+		reactions: "└ ",
+		replies:   "└ ",
+		codeStart: "╭ ",
+		codeLine:  "│",
+		codeEnd:   "╰",
+		unread:    "── new messages ──",
+		dayStart:  "── ",
+		dayEnd:    " ──",
+		pending:   "⋯",
+		delivered: "✓",
+		failed:    "✗ not delivered",
+	}
+	// plainMarkers are read out in a meaningful way by screen readers
+	plainMarkers = markerSet{
+		quote:     "quote: ",
+		reactions: "reactions: ",
+		replies:   "",
+		codeStart: "code ",
+		codeLine:  " ",
+		codeEnd:   "end of code",
+		unread:    "new messages",
+		pending:   "sending",
+		failed:    "not delivered",
+	}
+
+	// plainMode disables all colors and box-drawing markers, set with LOCALCHAT_PLAIN
+	plainMode bool
+)
+
+// getMarkers returns the markers of the current mode.
+func getMarkers() markerSet {
+	if plainMode {
+		return plainMarkers
+	}
+	return boxMarkers
+}
+
+// plainTheme returns the theme of the plain mode, which uses the default colors of the terminal
+// everywhere.
+func plainTheme() theme {
+	return theme{
+		Name:               "plain",
+		Background:         "-",
+		ContrastBackground: "-",
+		Text:               "-",
+		SecondaryText:      "-",
+		Border:             "-",
+		Title:              "-",
+		Muted:              "-",
+		Accent:             "-",
+		Error:              "-",
+		Quote:              "-",
+		Reaction:           "-",
+		Unread:             "-",
+		DefaultUser:        "-",
+		OwnMention:         "-:-",
+		InputLabel:         "-",
+		InputBackground:    "-",
+		InputText:          "-",
+		CommandBackground:  "-",
+		QuoteInput:         "-",
+		ReactionInput:      "-",
+		SettingInput:       "-",
+		Code:               "-",
+		CodeBorder:         "-",
+		CodeKeyword:        "-",
+		CodeString:         "-",
+		CodeNumber:         "-",
+		CodeComment:        "-",
+	}
+}
+
+// relativeLuminance returns the WCAG relative luminance of the color, between 0 (black) and 1 (white).
+func relativeLuminance(color tcell.Color) float64 {
+	linear := func(channel int32) float64 {
+		c := float64(channel) / 255
+		if c <= 0.03928 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+
+	r, g, b := color.RGB()
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b)
+}
+
+// contrastRatio returns the WCAG contrast ratio of two colors, between 1 and 21.
+func contrastRatio(a tcell.Color, b tcell.Color) float64 {
+	lighter, darker := relativeLuminance(a), relativeLuminance(b)
+	if lighter < darker {
+		lighter, darker = darker, lighter
+	}
+	return (lighter + 0.05) / (darker + 0.05)
+}
+
+// ensureContrast returns the color unchanged if it is readable on the background. Otherwise it is
+// mixed with white on dark backgrounds, or with black on light ones, until the contrast ratio
+// reaches minContrastRatio. Unknown colors are returned unchanged.
+func ensureContrast(color string, background string) string {
+	fg, bg := tcell.GetColor(color), tcell.GetColor(background)
+	if fg == tcell.ColorDefault || bg == tcell.ColorDefault || contrastRatio(fg, bg) >= minContrastRatio {
+		return color
+	}
+
+	target := tcell.ColorWhite
+	if contrastRatio(tcell.ColorBlack, bg) > contrastRatio(tcell.ColorWhite, bg) {
+		target = tcell.ColorBlack
+	}
+
+	r, g, b := fg.RGB()
+	tr, tg, tb := target.RGB()
+	mix := func(from int32, to int32, amount float64) int32 {
+		return from + int32(math.Round(float64(to-from)*amount))
+	}
+
+	for step := 1; step <= 20; step++ {
+		amount := float64(step) / 20
+		adjusted := tcell.NewRGBColor(mix(r, tr, amount), mix(g, tg, amount), mix(b, tb, amount))
+		if contrastRatio(adjusted, bg) >= minContrastRatio {
+			return fmt.Sprintf("#%06x", adjusted.Hex())
+		}
+	}
+
+	return fmt.Sprintf("#%06x", target.Hex())
+}
+
+// ensurePaletteContrast returns the color unchanged if it is readable on the background.
+// Otherwise it returns the color of the palette with the given number of colors which is closest
+// to it and readable, or the most readable one if none is. Downgrading a readable color to a small
+// palette can make it unreadable again, e.g. a light blue becomes navy with 16 colors.
+func ensurePaletteContrast(color string, background string, colors int) string {
+	fg, bg := tcell.GetColor(color), tcell.GetColor(background)
+	if fg == tcell.ColorDefault || bg == tcell.ColorDefault || contrastRatio(fg, bg) >= minContrastRatio {
+		return color
+	}
+
+	r, g, b := fg.RGB()
+	distance := func(c tcell.Color) int32 {
+		cr, cg, cb := c.RGB()
+		return (cr-r)*(cr-r) + (cg-g)*(cg-g) + (cb-b)*(cb-b)
+	}
+
+	best, bestReadable := tcell.ColorDefault, tcell.ColorDefault
+	for i := 0; i < min(colors, 256); i++ {
+		candidate := tcell.PaletteColor(i)
+		ratio := contrastRatio(candidate, bg)
+		if ratio >= minContrastRatio && (bestReadable == tcell.ColorDefault || distance(candidate) < distance(bestReadable)) {
+			bestReadable = candidate
+		}
+		if best == tcell.ColorDefault || ratio > contrastRatio(best, bg) {
+			best = candidate
+		}
+	}
+
+	if bestReadable != tcell.ColorDefault {
+		return bestReadable.Name(true)
+	}
+	return best.Name(true)
+}
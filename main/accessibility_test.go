@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContrastRatio(t *testing.T) {
+	assert.InDelta(t, 21, contrastRatio(tcell.ColorBlack, tcell.ColorWhite), 0.01)
+	assert.InDelta(t, 21, contrastRatio(tcell.ColorWhite, tcell.ColorBlack), 0.01)
+	assert.InDelta(t, 1, contrastRatio(tcell.ColorRed, tcell.ColorRed), 0.01)
+}
+
+func TestEnsureContrast(t *testing.T) {
+	tests := []struct {
+		name       string
+		color      string
+		background string
+		unchanged  bool
+	}{
+		{name: "readable color", color: "#ffcc00", background: "black", unchanged: true},
+		{name: "black on black", color: "#000000", background: "black"},
+		{name: "dark blue on black", color: "#000080", background: "black"},
+		{name: "yellow on white", color: "#ffff00", background: "white"},
+		{name: "unknown color", color: "unknown", background: "black", unchanged: true},
+		{name: "default background", color: "#000000", background: "-", unchanged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ensureContrast(tt.color, tt.background)
+			if tt.unchanged {
+				assert.Equal(t, tt.color, got)
+				return
+			}
+			assert.NotEqual(t, tt.color, got)
+			assert.GreaterOrEqual(t, contrastRatio(tcell.GetColor(got), tcell.GetColor(tt.background)), minContrastRatio)
+		})
+	}
+}
+
+func TestGetDisplayColor_16Colors(t *testing.T) {
+	originalTheme := currentTheme
+	t.Cleanup(func() { currentTheme = originalTheme })
+	currentTheme = builtinThemes["16-color"]
+
+	// light blue is readable on black, but the closest of the 16 colors, navy, is not
+	setTestClientList(t,
+		client{ClientDbID: "a", ClientUsername: "Alice", ClientColor: "#5050ff"},
+		client{ClientDbID: "b", ClientUsername: "Bob", ClientColor: "#ff2020"})
+
+	for _, id := range []string{"a", "b"} {
+		color := getDisplayColor(id)
+		assert.False(t, tcell.GetColor(color).IsRGB(), "%s is no palette color", color)
+		assert.GreaterOrEqual(t, contrastRatio(tcell.GetColor(color), tcell.GetColor(currentTheme.Background)), minContrastRatio, color)
+	}
+	assert.Equal(t, "red", getDisplayColor("b"))
+}
+
+func TestEnsurePaletteContrast(t *testing.T) {
+	assert.Equal(t, "red", ensurePaletteContrast("red", "black", 16))
+	assert.Equal(t, "unknown", ensurePaletteContrast("unknown", "black", 16))
+	// the closest readable color to navy on black
+	color := ensurePaletteContrast("navy", "black", 16)
+	assert.GreaterOrEqual(t, contrastRatio(tcell.GetColor(color), tcell.ColorBlack), minContrastRatio)
+	// no color of the palette is readable, the most readable one is used
+	assert.Equal(t, "black", ensurePaletteContrast("maroon", "#404040", 2))
+}
+
+func TestPlainMode(t *testing.T) {
+	originalTheme := currentTheme
+	t.Cleanup(func() {
+		plainMode = false
+		currentTheme = originalTheme
+	})
+	plainMode = true
+	currentTheme = plainTheme()
+
+	setTestClientList(t, client{ClientDbID: "a", ClientUsername: "Alice", ClientColor: "#ff0000"})
+
+	assert.Equal(t, "-", getDisplayColor("a"))
+	assert.Equal(t, "\n"+margin+"[-]reactions: [ smile ][-]", checkForReactions([]reactionType{{ReactionContext: "smile"}}))
+	assert.Equal(t, "\n"+margin+"[-]3 replies[-]", formatReplyCount(3))
+	assert.Equal(t, "[-]code go[-]\n[-] [-] x := [-]1[-]\n[-]end of code[-]",
+		renderMarkdown("```go\nx := 1\n```", nil))
+}
@@ -3,7 +3,7 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/gen2brain/beeep"
 	"github.com/rivo/tview"
@@ -19,40 +19,46 @@ type notifier interface {
 // beeepNotifier is a type that represents a notifier using the beeep package.
 type beeepNotifier struct{}
 
-// Notify sends a notification with the specified title and message using the beeep package. Icons are ignored right now.
-func (bn *beeepNotifier) Notify(title, message, _ string) error {
-	return beeep.Notify(title, message, "")
+// Notify sends a notification with the specified title, message and icon using the beeep package.
+func (bn *beeepNotifier) Notify(title, message, icon string) error {
+	return beeep.Notify(title, message, icon)
 }
 
-// desktopNotification sends a desktop notification with the given payload.
-// It decodes the base64 encoded message context, retrieves the username from the cache,
-// and then calls the notifier to send the notification.
-func (app *app) desktopNotification(payload *messagePayload) error {
-
-	decodedString, err := decodeBase64ToString(payload.MessageType.MessageContext)
-	if err != nil {
-		fmt.Println("Error decoding base64 to string:", err)
-	}
-
-	userFromCache := getUsernameFromCache(payload.ClientType.ClientDbID)
-
-	return app.notifier.Notify("message from "+userFromCache, decodedString, "")
+// desktopNotification queues a desktop notification for the given payload.
+// Messages arriving within a short window are coalesced into a single notification
+// and notifications are rate limited per sender, see notificationBatcher.
+func (app *app) desktopNotification(payload *messagePayload) {
+	app.notifications.add(*payload)
 }
 
 // createApp creates and initializes a new instance of the app struct.
-// It sets up the user interface, establishes a connection, and returns the app.
-func createApp() *app {
+// It loads the client id, sets up the user interface, establishes a connection, and returns the app.
+func createApp() (*app, error) {
+	if err := setupClientID(); err != nil {
+		return nil, err
+	}
+	loadUnreadState()
+
 	ui := tview.NewApplication()
 
 	conn, err := createConnection(getEnvIP(), getEnvPort())
 	if err != nil {
-		log.Fatalf("Failed to create connection: %v", err)
+		return nil, fmt.Errorf("failed to create connection: %w", err)
 	}
 
 	app, err := newApp(ui, conn)
 	if err != nil {
-		log.Fatalf("Failed to initialize app: %v", err)
+		return nil, fmt.Errorf("failed to initialize app: %w", err)
+	}
+
+	// the archive is optional, the chat works without it
+	archivePath, err := getArchivePath()
+	if err == nil {
+		app.archive, err = openArchive(archivePath)
+	}
+	if err != nil {
+		slog.Warn("opening message archive failed", "err", err)
 	}
 
-	return app
+	return app, nil
 }
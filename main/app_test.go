@@ -88,23 +88,21 @@ func TestApp_desktopNotification(t *testing.T) {
 		payload *messagePayload
 	}
 	tests := []struct {
-		fields  fields
-		args    args
-		name    string
-		wantErr bool
+		fields fields
+		args   args
+		name   string
 	}{
 		// TODO: Add test cases.
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			app := &app{
-				ui:       tt.fields.ui,
-				notifier: tt.fields.notifier,
-				conn:     tt.fields.conn,
-			}
-			if err := app.desktopNotification(tt.args.payload); (err != nil) != tt.wantErr {
-				t.Errorf("App.desktopNotification() error = %v, wantErr %v", err, tt.wantErr)
+				ui:            tt.fields.ui,
+				notifier:      tt.fields.notifier,
+				notifications: newNotificationBatcher(tt.fields.notifier),
+				conn:          tt.fields.conn,
 			}
+			app.desktopNotification(tt.args.payload)
 		})
 	}
 }
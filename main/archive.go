@@ -0,0 +1,207 @@
+// main package
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+)
+
+// archiveMaxLineSize is the maximum size of a single archived message, images included.
+const archiveMaxLineSize = 64 * 1024 * 1024
+
+// messageArchive is a local append-only log of all received messages in JSON Lines format,
+// deduplicated by MessageDbID. A message is appended again whenever it changes (e.g. new reactions),
+// the latest version wins when the archive is loaded.
+type messageArchive struct {
+	file     *os.File
+	messages map[string]messagePayload
+	path     string
+	order    []string
+	mu       sync.Mutex
+}
+
+// getArchivePath returns the path of the message archive in ~/.localchat/archive/.
+func getArchivePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".localchat", "archive", "messages.jsonl"), nil
+}
+
+// openArchive loads the archive at the given path and opens it for appending.
+// The file is compacted if it contains outdated versions of messages.
+func openArchive(path string) (*messageArchive, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	archive := &messageArchive{
+		path:     path,
+		messages: make(map[string]messagePayload),
+	}
+
+	lines, err := archive.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if lines > len(archive.order) {
+		if err := archive.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	archive.file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+// load reads all archived messages and returns the number of lines read.
+// Lines that cannot be parsed are skipped.
+func (ma *messageArchive) load() (int, error) {
+	file, err := os.Open(ma.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), archiveMaxLineSize)
+
+	lines := 0
+	for scanner.Scan() {
+		lines++
+
+		var payload messagePayload
+		if err := json.Unmarshal(scanner.Bytes(), &payload); err != nil {
+			slog.Error("parsing archived message failed", "err", err)
+			continue
+		}
+
+		ma.put(payload)
+	}
+
+	return lines, scanner.Err()
+}
+
+// compact rewrites the archive file with the latest version of every message only.
+func (ma *messageArchive) compact() error {
+	tmpPath := ma.path + ".tmp"
+
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, id := range ma.order {
+		if err := encoder.Encode(ma.messages[id]); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, ma.path)
+}
+
+// put adds or replaces the message in memory and reports whether anything changed.
+func (ma *messageArchive) put(payload messagePayload) bool {
+	id := payload.MessageType.MessageDbID
+
+	existing, exists := ma.messages[id]
+	if exists && reflect.DeepEqual(existing, payload) {
+		return false
+	}
+	if !exists {
+		ma.order = append(ma.order, id)
+	}
+	ma.messages[id] = payload
+
+	return true
+}
+
+// store archives the message unless the same version is archived already.
+// It is safe to call store on a nil archive, which does nothing.
+func (ma *messageArchive) store(payload messagePayload) error {
+	if ma == nil || payload.MessageType.MessageDbID == "" {
+		return nil
+	}
+
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+
+	if !ma.put(payload) {
+		return nil
+	}
+
+	line, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = ma.file.Write(append(line, '\n'))
+	return err
+}
+
+// getMessages returns all archived messages in the order they were first received.
+func (ma *messageArchive) getMessages() []messagePayload {
+	if ma == nil {
+		return nil
+	}
+
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+
+	messages := make([]messagePayload, 0, len(ma.order))
+	for _, id := range ma.order {
+		messages = append(messages, ma.messages[id])
+	}
+
+	return messages
+}
+
+// getLastMessages returns the last n archived messages.
+func (ma *messageArchive) getLastMessages(n int) []messagePayload {
+	messages := ma.getMessages()
+	if len(messages) > n {
+		return messages[len(messages)-n:]
+	}
+	return messages
+}
+
+// close flushes and closes the archive file.
+func (ma *messageArchive) close() error {
+	if ma == nil {
+		return nil
+	}
+
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+
+	if err := ma.file.Sync(); err != nil {
+		return err
+	}
+	return ma.file.Close()
+}
@@ -0,0 +1,66 @@
+// main package
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
+
+func TestMessageArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive", "messages.jsonl")
+
+	archive, err := openArchive(path)
+	assert.NoError(t, err)
+
+	first := newTestMessagePayload("1", "first")
+	second := newTestMessagePayload("2", "second")
+
+	assert.NoError(t, archive.store(first))
+	assert.NoError(t, archive.store(second))
+	// the same version is not archived twice
+	assert.NoError(t, archive.store(first))
+	assert.Equal(t, 2, countLines(t, path))
+
+	// a changed version is appended and replaces the old one
+	edited := first
+	edited.MessageType.Edited = true
+	assert.NoError(t, archive.store(edited))
+	assert.Equal(t, 3, countLines(t, path))
+	assert.Equal(t, []messagePayload{edited, second}, archive.getMessages())
+	assert.NoError(t, archive.close())
+
+	// reopening loads the latest versions and compacts the file
+	reopened, err := openArchive(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []messagePayload{edited, second}, reopened.getMessages())
+	assert.Equal(t, []messagePayload{second}, reopened.getLastMessages(1))
+	assert.Equal(t, 2, countLines(t, path))
+	assert.NoError(t, reopened.close())
+}
+
+func TestMessageArchive_Nil(t *testing.T) {
+	var archive *messageArchive
+
+	assert.NoError(t, archive.store(newTestMessagePayload("1", "hello")))
+	assert.Empty(t, archive.getMessages())
+	assert.NoError(t, archive.close())
+}
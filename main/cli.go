@@ -0,0 +1,194 @@
+// main package
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// connectHeadless connects and authenticates at the server without starting the GUI.
+func connectHeadless() (*websocket.Conn, error) {
+	if err := setupClientID(); err != nil {
+		return nil, err
+	}
+
+	conn, err := createConnection(getEnvIP(), getEnvPort())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection: %v", err)
+	}
+
+	if err := authenticateClientAtSocket(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// closeHeadless closes the connection with a close message and waits shortly for the server to
+// acknowledge it, so that previously written messages are not lost.
+func closeHeadless(conn *websocket.Conn) {
+	err := conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	if err == nil {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				break
+			}
+		}
+	}
+	conn.Close()
+}
+
+// runSendCommand implements "localterm send [text]", which sends a single message and exits.
+// Without text, or with "-", the message is read from stdin. It returns the exit code.
+func runSendCommand(args []string) int {
+	flags := flag.NewFlagSet("send", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	text := strings.Join(flags.Args(), " ")
+	if text == "" || text == "-" {
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error reading stdin:", err)
+			return 1
+		}
+		text = strings.TrimRight(string(input), "\n")
+	}
+	if strings.TrimSpace(text) == "" {
+		fmt.Fprintln(os.Stderr, "nothing to send")
+		return 2
+	}
+
+	conn, err := connectHeadless()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeHeadless(conn)
+
+	if err := writeJSON(conn, newMessagePayload(text)); err != nil {
+		fmt.Fprintln(os.Stderr, "error writing messagePayload:", err)
+		return 1
+	}
+
+	return 0
+}
+
+// runTailCommand implements "localterm tail", which prints incoming messages to stdout until
+// interrupted, as plain text or JSON Lines. With -history the last 100 messages are printed first.
+func runTailCommand(args []string) int {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	format := flags.String("format", exportFormatText, "output format: txt or jsonl")
+	history := flags.Bool("history", false, "print the last 100 messages first")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != exportFormatText && *format != exportFormatJSONL {
+		fmt.Fprintf(os.Stderr, "unknown tail format %q\n", *format)
+		return 2
+	}
+
+	conn, err := connectHeadless()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- tailMessages(conn, os.Stdout, *format, *history)
+	}()
+
+	select {
+	case <-interrupt:
+		// the reading goroutine receives the close answer of the server and returns
+		if err := conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second)); err == nil {
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+			}
+		}
+		conn.Close()
+		return 0
+	case err := <-done:
+		conn.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "read:", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// tailMessages reads payloads from the connection and writes every message to out.
+// It returns when the connection is closed.
+func tailMessages(conn *websocket.Conn, out io.Writer, format string, history bool) error {
+	historyRequested := false
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return err
+		}
+
+		var msg genericMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			continue
+		}
+
+		switch msg.PayloadType {
+		case clientListTypeConst:
+			clientListPayload, err := unmarshallMessageToClientListPayload(message)
+			if err != nil {
+				continue
+			}
+			setClientList(&clientListPayload)
+
+			// the client list is sent again on every change, request the history only once
+			if history && !historyRequested {
+				historyRequested = true
+				retrieveLast100Messages(conn)
+			}
+
+		case messageListTypeConst:
+			if !history {
+				continue
+			}
+			history = false
+			messageListPayload := unmarshallMessageToMessageListPayload(message)
+			if err := exportMessages(out, messageListPayload.MessageList, format); err != nil {
+				return err
+			}
+
+		case messageTypeConst:
+			payload, err := unmarshallPayloadToMessagePayload(message)
+			if err != nil {
+				continue
+			}
+			if err := exportMessages(out, []messagePayload{payload}, format); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// main package
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestWebsocketServer starts a websocket server that hands every connection to handler.
+// It returns a client connection to that server.
+func newTestWebsocketServer(t *testing.T, handler func(conn *websocket.Conn)) *websocket.Conn {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(conn)
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to dial test server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestTailMessages(t *testing.T) {
+	t.Cleanup(func() {
+		setClientList(&clientListStruct{})
+	})
+
+	message := newTestMessagePayload("1", "deploy finished")
+	message.MessageType.MessageDate = "2024-05-01"
+	message.MessageType.MessageTime = "12:00"
+
+	conn := newTestWebsocketServer(t, func(conn *websocket.Conn) {
+		conn.WriteJSON(struct {
+			Clients     []client    `json:"clients"`
+			PayloadType payloadType `json:"payloadType"`
+		}{
+			Clients:     []client{{ClientDbID: "1", ClientUsername: "CI"}},
+			PayloadType: clientListTypeConst,
+		})
+		conn.WriteJSON(message)
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	})
+
+	var out bytes.Buffer
+	assert.NoError(t, tailMessages(conn, &out, exportFormatText, false))
+	assert.Equal(t, "[2024-05-01 12:00] CI: deploy finished\n", out.String())
+}
+
+func TestRunSendCommandUsesStoredClientID(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("DEV", "")
+	idDir := filepath.Join(homeDir, ".localchat", "id")
+	assert.NoError(t, os.MkdirAll(idDir, 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(idDir, "id.txt"), []byte("stored-id"), 0o600))
+
+	previousEnvVars := envVars
+	t.Cleanup(func() {
+		envVars = previousEnvVars
+	})
+
+	frames := make(chan []byte, 2)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, frame, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			frames <- frame
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	assert.NoError(t, err)
+	envVars.IP, envVars.Port, envVars.ID = host, port, ""
+
+	assert.Equal(t, 0, runSendCommand([]string{"deploy finished"}))
+
+	var authentication authenticationPayload
+	assert.NoError(t, json.Unmarshal(<-frames, &authentication))
+	assert.Equal(t, "stored-id", authentication.ClientDbID)
+
+	var message messagePayload
+	assert.NoError(t, json.Unmarshal(<-frames, &message))
+	assert.Equal(t, "stored-id", message.ClientType.ClientDbID)
+}
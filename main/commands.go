@@ -0,0 +1,554 @@
+// main package
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const helpPageName = "help"
+
+// commandArg describes a single argument of a slash command.
+type commandArg struct {
+	// validate checks the value of the argument, it may be nil
+	validate func(value string) error
+	// complete returns completion candidates for a prefix of the argument, it may be nil
+	complete func(prefix string) []string
+	name     string
+	required bool
+	// rest arguments take the remainder of the line, spaces included; only the last argument may be one
+	rest bool
+	// emoji arguments are message text or reactions, their :shortcodes: are replaced with emoji
+	emoji bool
+}
+
+// inputKind decides how the input field is styled while a command is typed.
+type inputKind int
+
+const (
+	inputKindMessage inputKind = iota
+	inputKindQuote
+	inputKindReaction
+	inputKindSetting
+)
+
+// slashCommand is a command typed into the input field, e.g. "/search term".
+type slashCommand struct {
+	handler func(app *app, args []string) error
+	name    string
+	help    string
+	aliases []string
+	args    []commandArg
+	kind    inputKind
+}
+
+// usage returns the command with its arguments, e.g. "/q <index> <text>" or "/search [term]".
+func (sc *slashCommand) usage() string {
+	var builder strings.Builder
+	builder.WriteString(sc.name)
+	for _, arg := range sc.args {
+		if arg.required {
+			builder.WriteString(" <" + arg.name + ">")
+		} else {
+			builder.WriteString(" [" + arg.name + "]")
+		}
+	}
+	return builder.String()
+}
+
+// commandRegistry holds all slash commands by name and alias.
+type commandRegistry struct {
+	commands map[string]*slashCommand
+}
+
+var commands = &commandRegistry{commands: make(map[string]*slashCommand)}
+
+func init() {
+	registerBuiltinCommands(commands)
+}
+
+// register adds the command under its name and all aliases.
+func (cr *commandRegistry) register(command *slashCommand) {
+	cr.commands[command.name] = command
+	for _, alias := range command.aliases {
+		cr.commands[alias] = command
+	}
+}
+
+// lookup returns the command registered under the name or alias.
+func (cr *commandRegistry) lookup(name string) (*slashCommand, bool) {
+	command, ok := cr.commands[name]
+	return command, ok
+}
+
+// list returns every registered command once, sorted by name.
+func (cr *commandRegistry) list() []*slashCommand {
+	seen := make(map[*slashCommand]bool)
+	var list []*slashCommand
+	for _, command := range cr.commands {
+		if !seen[command] {
+			seen[command] = true
+			list = append(list, command)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+	return list
+}
+
+// names returns all command names and aliases including those of plugins, sorted.
+func (cr *commandRegistry) names() []string {
+	var names []string
+	for name := range cr.commands {
+		names = append(names, name)
+	}
+	for _, p := range getPlugins() {
+		names = append(names, p.commands()...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	legacyIndexCommandRegex = regexp.MustCompile(`^(/[qr])([0-9]{3})$`)
+	legacyInputRegex        = regexp.MustCompile(`^\[([0-9]{3})\] (>{1,2}) `)
+)
+
+// translateLegacyInput rewrites the legacy forms "[042] > text" and "[042] >> reaction" to the
+// commands "/q 042 text" and "/r 042 reaction". Other input is returned unchanged.
+func translateLegacyInput(text string) string {
+	matches := legacyInputRegex.FindStringSubmatch(text)
+	if matches == nil {
+		return text
+	}
+
+	name := "/q"
+	if matches[2] == ">>" {
+		name = "/r"
+	}
+	return name + " " + matches[1] + " " + text[len(matches[0]):]
+}
+
+// getInputKind returns the kind of the command being typed, as soon as its name is complete.
+// Unknown commands and messages are inputKindMessage.
+func getInputKind(text string) inputKind {
+	name, _, ok := splitCommand(translateLegacyInput(text))
+	if !ok || !strings.Contains(strings.TrimLeft(text, " "), " ") {
+		return inputKindMessage
+	}
+
+	command, found := commands.lookup(name)
+	if !found {
+		return inputKindMessage
+	}
+	return command.kind
+}
+
+// splitCommand splits the input into command name and argument. The legacy form "/q042 text" is
+// split like "/q 042 text". It returns false if the input is not a command.
+func splitCommand(text string) (name string, argument string, ok bool) {
+	text = strings.TrimLeft(text, " ")
+	if !strings.HasPrefix(text, "/") || strings.HasPrefix(text, "//") {
+		return "", "", false
+	}
+
+	name, argument, _ = strings.Cut(text, " ")
+	if matches := legacyIndexCommandRegex.FindStringSubmatch(name); matches != nil {
+		name = matches[1]
+		argument = matches[2] + " " + argument
+	}
+
+	return name, argument, true
+}
+
+// parseCommandArgs splits and validates the argument according to the argument spec.
+func parseCommandArgs(spec []commandArg, argument string) ([]string, error) {
+	var args []string
+	remaining := strings.TrimLeft(argument, " ")
+
+	for _, arg := range spec {
+		var value string
+		if arg.rest {
+			value = strings.TrimSpace(remaining)
+			remaining = ""
+		} else {
+			value, remaining, _ = strings.Cut(remaining, " ")
+			remaining = strings.TrimLeft(remaining, " ")
+		}
+
+		if value == "" {
+			if arg.required {
+				return nil, fmt.Errorf("missing argument <%s>", arg.name)
+			}
+			args = append(args, "")
+			continue
+		}
+
+		if arg.validate != nil {
+			if err := arg.validate(value); err != nil {
+				return nil, fmt.Errorf("invalid <%s>: %v", arg.name, err)
+			}
+		}
+		args = append(args, value)
+	}
+
+	if strings.TrimSpace(remaining) != "" {
+		return nil, fmt.Errorf("too many arguments")
+	}
+
+	return args, nil
+}
+
+// executeCommand runs the slash command in text. It returns false if the text is no command.
+// Unknown commands and invalid arguments are reported as error.
+func executeCommand(app *app, text string) (bool, error) {
+	name, argument, ok := splitCommand(text)
+	if !ok {
+		return false, nil
+	}
+
+	command, found := commands.lookup(name)
+	if !found {
+		// commands provided by plugins
+		if p, ok := findPluginCommand(name); ok {
+			p.handleCommand(name, strings.TrimSpace(argument), app)
+			return true, nil
+		}
+		return true, fmt.Errorf("unknown command %s, see /help (use // to send a message starting with /)", name)
+	}
+
+	args, err := parseCommandArgs(command.args, argument)
+	if err != nil {
+		return true, fmt.Errorf("%v, usage: %s", err, command.usage())
+	}
+	for i, arg := range command.args {
+		if arg.emoji {
+			args[i] = expandShortcodes(args[i])
+		}
+	}
+
+	return true, command.handler(app, args)
+}
+
+// completeCommand completes the command name, or the argument of a command, at the end of text.
+// A single candidate is completed including a trailing space, multiple candidates up to their
+// longest common prefix. All candidates are returned to be shown as hint.
+func completeCommand(text string) (completed string, candidates []string, ok bool) {
+	name, argument, isCommand := splitCommand(text)
+	if !isCommand {
+		return text, nil, false
+	}
+
+	var prefix string
+	if !strings.Contains(text, " ") {
+		prefix = name
+		candidates = filterPrefix(commands.names(), prefix)
+	} else {
+		command, found := commands.lookup(name)
+		if !found {
+			return text, nil, false
+		}
+
+		// the argument being typed is the last word of the input
+		argIndex := len(strings.Fields(argument))
+		if argument == "" || strings.HasSuffix(argument, " ") {
+			prefix = ""
+		} else {
+			argIndex--
+			prefix = argument[strings.LastIndex(argument, " ")+1:]
+		}
+		if argIndex >= len(command.args) || command.args[argIndex].complete == nil {
+			return text, nil, false
+		}
+		candidates = filterPrefix(command.args[argIndex].complete(prefix), prefix)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return text, nil, false
+	case 1:
+		return strings.TrimSuffix(text, prefix) + candidates[0] + " ", candidates, true
+	}
+
+	common := candidates[0]
+	for _, candidate := range candidates[1:] {
+		common = commonPrefixFold(common, candidate)
+	}
+	return strings.TrimSuffix(text, prefix) + common, candidates, len(common) > len(prefix)
+}
+
+// filterPrefix returns the values starting with prefix.
+func filterPrefix(values []string, prefix string) []string {
+	var filtered []string
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			filtered = append(filtered, value)
+		}
+	}
+	return filtered
+}
+
+// validateMessageIndex checks that the value is a three digit index of a loaded message.
+func validateMessageIndex(value string) error {
+	if len(value) != 3 || strings.Trim(value, "0123456789") != "" {
+		return errors.New("expected three digits, e.g. 042")
+	}
+	if atoi(value) >= len(getMessagesFromCache()) {
+		return fmt.Errorf("there is no message %s", value)
+	}
+	return nil
+}
+
+// validateHexColor checks that the value is a hex color like #ff8800.
+func validateHexColor(value string) error {
+	if !checkIfHexColor(value) {
+		return errors.New("expected a hex color, e.g. #ff8800")
+	}
+	return nil
+}
+
+func registerBuiltinCommands(cr *commandRegistry) {
+	cr.register(&slashCommand{
+		name: "/help",
+		help: "list all commands or show the usage of one",
+		args: []commandArg{{name: "command", complete: func(string) []string { return cr.names() }}},
+		handler: func(app *app, args []string) error {
+			if args[0] == "" {
+				app.showHelpView()
+				return nil
+			}
+			command, ok := cr.lookup("/" + strings.TrimPrefix(args[0], "/"))
+			if !ok {
+				return fmt.Errorf("unknown command %s", args[0])
+			}
+			writeSystemLine(command.usage() + " - " + command.help)
+			return nil
+		},
+	})
+
+	cr.register(&slashCommand{
+		name:    "/q",
+		aliases: []string{"/quote"},
+		help:    "quote a message, e.g. /q042 I agree",
+		kind:    inputKindQuote,
+		args: []commandArg{
+			{name: "index", required: true, validate: validateMessageIndex},
+			{name: "text", required: true, rest: true, emoji: true},
+		},
+		handler: func(app *app, args []string) error {
+			quotedMessagePayload := getMessageFromCache(atoi(args[0]))
+			return app.sendMessagePayload(newQuotedMessagePayload(quotedMessagePayload, args[1]))
+		},
+	})
+
+	cr.register(&slashCommand{
+		name: "/thread",
+		help: "open the thread of a message to read and write replies",
+		args: []commandArg{{name: "index", required: true, validate: validateMessageIndex}},
+		handler: func(app *app, args []string) error {
+			app.showThread(atoi(args[0]))
+			return nil
+		},
+	})
+
+	cr.register(&slashCommand{
+		name: "/reply",
+		help: "reply in the thread of a message, e.g. /reply 042 sounds good",
+		args: []commandArg{
+			{name: "index", required: true, validate: validateMessageIndex},
+			{name: "text", required: true, rest: true, emoji: true},
+		},
+		handler: func(app *app, args []string) error {
+			parentMessagePayload := getMessageFromCache(atoi(args[0]))
+			return app.sendMessagePayload(newReplyMessagePayload(parentMessagePayload, args[1]))
+		},
+	})
+
+	cr.register(&slashCommand{
+		name: "/retry",
+		help: "send a message marked as not delivered again, without index all of them, e.g. /retry 042",
+		args: []commandArg{{name: "index", validate: validateMessageIndex}},
+		handler: func(app *app, args []string) error {
+			if args[0] != "" {
+				return app.retryMessage(getMessageFromCache(atoi(args[0])))
+			}
+
+			failed := getFailedMessages()
+			if len(failed) == 0 {
+				return errors.New("no message is marked as not delivered")
+			}
+			for _, payload := range failed {
+				if err := app.retryMessage(payload); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+
+	cr.register(&slashCommand{
+		name:    "/r",
+		aliases: []string{"/react"},
+		help:    "react to a message, e.g. /r042 :+1:, reacting again removes it, without a reaction the emoji picker opens",
+		kind:    inputKindReaction,
+		args: []commandArg{
+			{name: "index", required: true, validate: validateMessageIndex},
+			{name: "reaction", rest: true, emoji: true},
+		},
+		handler: func(app *app, args []string) error {
+			reactedMessagePayload := getMessageFromCache(atoi(args[0]))
+			if args[1] == "" {
+				app.showEmojiPicker(func(emoji string) {
+					app.ui.SetFocus(inputField)
+					if emoji == "" {
+						return
+					}
+					if err := writeJSON(app.conn, newReactionTogglePayload(reactedMessagePayload.MessageType.MessageDbID, emoji)); err != nil {
+						showInputHint(err.Error())
+					}
+				})
+				return nil
+			}
+			return writeJSON(app.conn, newReactionTogglePayload(reactedMessagePayload.MessageType.MessageDbID, args[1]))
+		},
+	})
+
+	cr.register(&slashCommand{
+		name:    "/sc",
+		aliases: []string{"/color"},
+		help:    "change the color of your username",
+		kind:    inputKindSetting,
+		args:    []commandArg{{name: "color", required: true, validate: validateHexColor}},
+		handler: func(app *app, args []string) error {
+			return writeJSON(app.conn, newProfileUpdatePayload(args[0]))
+		},
+	})
+
+	cr.register(&slashCommand{
+		name: "/mentions",
+		help: "list all loaded messages mentioning you",
+		handler: func(app *app, _ []string) error {
+			app.showMentionsView()
+			return nil
+		},
+	})
+
+	cr.register(&slashCommand{
+		name: "/search",
+		help: "search the loaded messages, or the local archive with -a <term>",
+		args: []commandArg{{name: "term", rest: true}},
+		handler: func(app *app, args []string) error {
+			term, archive := parseSearchArgument(args[0])
+			if !archive {
+				app.showSearchBar(term)
+				return nil
+			}
+			if term == "" {
+				return errors.New("missing argument <term>, usage: /search -a <term>")
+			}
+			app.showArchiveSearchResults(term)
+			return nil
+		},
+	})
+
+	cr.register(&slashCommand{
+		name: "/quit",
+		help: "quit localterm, pending messages are given a moment to be delivered",
+		handler: func(app *app, _ []string) error {
+			app.quit(errQuit)
+			return nil
+		},
+	})
+
+	cr.register(&slashCommand{
+		name: "/log",
+		help: "show recent warnings and errors, the full log is written to ~/.localchat/logs",
+		handler: func(app *app, _ []string) error {
+			app.showLogView()
+			return nil
+		},
+	})
+
+	cr.register(&slashCommand{
+		name: "/keys",
+		help: "list the key bindings, configured with LOCALCHAT_KEYMAP",
+		handler: func(app *app, _ []string) error {
+			app.showKeysView()
+			return nil
+		},
+	})
+
+	cr.register(&slashCommand{
+		name: "/links",
+		help: "list recent links, optionally filtered, and open the selected one in the browser",
+		args: []commandArg{{name: "filter", rest: true}},
+		handler: func(app *app, args []string) error {
+			app.showLinksView(args[0])
+			return nil
+		},
+	})
+
+	cr.register(&slashCommand{
+		name: "/compose",
+		help: "open the multi-line composer, also opened with Alt+Enter or by pasting several lines",
+		args: []commandArg{{name: "text", rest: true, emoji: true}},
+		handler: func(app *app, args []string) error {
+			app.showComposer(args[0])
+			return nil
+		},
+	})
+
+	cr.register(&slashCommand{
+		name: "/export",
+		help: "export messages: -format md|html|jsonl|txt -o file -from date -to date -user name -a, quote values with spaces",
+		args: []commandArg{{
+			name: "options",
+			rest: true,
+		}},
+		handler: func(app *app, args []string) error {
+			app.exportCommand(args[0])
+			return nil
+		},
+	})
+}
+
+// showHelpView opens an overlay listing all commands. It is closed with Escape.
+func (app *app) showHelpView() {
+	textView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
+	textView.SetBorder(true).SetTitle(" commands ")
+
+	for _, command := range commands.list() {
+		aliases := ""
+		if len(command.aliases) > 0 {
+			aliases = " " + colorTag(currentTheme.Muted) + "(" + strings.Join(command.aliases, ", ") + ")[-]"
+		}
+		fmt.Fprintf(textView, "%s%s[-]%s\n    %s\n", colorTag(currentTheme.Accent), tview.Escape(command.usage()), aliases,
+			tview.Escape(command.help))
+	}
+
+	for _, p := range getPlugins() {
+		for _, command := range p.commands() {
+			fmt.Fprintf(textView, "%s%s[-]\n    provided by plugin %s\n", colorTag(currentTheme.Accent), command, tview.Escape(p.name()))
+		}
+	}
+
+	fmt.Fprint(textView, "\n"+colorTag(currentTheme.Muted)+"Tab completes commands, arguments and @usernames, Escape selects messages, Up/Down and Ctrl+R browse the input "+
+		"history, Alt+Enter opens the composer, /keys lists all key bindings, Escape closes this view[-]")
+
+	textView.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			app.hideOverlay(helpPageName)
+		}
+	})
+
+	app.showOverlay(helpPageName, textView)
+}
@@ -0,0 +1,173 @@
+// main package
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		wantName     string
+		wantArgument string
+		wantOk       bool
+	}{
+		{name: "plain message", text: "hello", wantOk: false},
+		{name: "escaped slash", text: "//usr is full", wantOk: false},
+		{name: "command without argument", text: "/help", wantName: "/help", wantOk: true},
+		{name: "command with argument", text: "/search build red", wantName: "/search", wantArgument: "build red", wantOk: true},
+		{name: "legacy quote", text: "/q042 I agree", wantName: "/q", wantArgument: "042 I agree", wantOk: true},
+		{name: "legacy reaction", text: "/r001 👍", wantName: "/r", wantArgument: "001 👍", wantOk: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, argument, ok := splitCommand(tt.text)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantArgument, argument)
+		})
+	}
+}
+
+func TestTranslateLegacyInput(t *testing.T) {
+	assert.Equal(t, "/q 042 I agree", translateLegacyInput("[042] > I agree"))
+	assert.Equal(t, "/r 001 👍", translateLegacyInput("[001] >> 👍"))
+	assert.Equal(t, "[090] abc", translateLegacyInput("[090] abc"))
+	assert.Equal(t, "hello", translateLegacyInput("hello"))
+}
+
+func TestGetInputKind(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want inputKind
+	}{
+		{name: "plain message", text: "hello", want: inputKindMessage},
+		{name: "quote", text: "/q042 I agree", want: inputKindQuote},
+		{name: "quote alias", text: "/quote 042 ", want: inputKindQuote},
+		{name: "legacy quote", text: "[123] > ", want: inputKindQuote},
+		{name: "reaction", text: "/r999 hello", want: inputKindReaction},
+		{name: "legacy reaction", text: "[234] >> ", want: inputKindReaction},
+		{name: "settings change", text: "/sc #ff0000", want: inputKindSetting},
+		{name: "incomplete command name", text: "/q", want: inputKindMessage},
+		{name: "other command", text: "/search term", want: inputKindMessage},
+		{name: "unknown command", text: "/p189 what's up", want: inputKindMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getInputKind(tt.text))
+		})
+	}
+}
+
+func TestExecuteCommand_ExpandsShortcodesInTextOnly(t *testing.T) {
+	var got []string
+	commands.register(&slashCommand{
+		name: "/shortcodes",
+		args: []commandArg{
+			{name: "option", required: true},
+			{name: "text", rest: true, emoji: true},
+		},
+		handler: func(_ *app, args []string) error {
+			got = args
+			return nil
+		},
+	})
+	t.Cleanup(func() { delete(commands.commands, "/shortcodes") })
+
+	isCommand, err := executeCommand(nil, "/shortcodes a:+1:b looks good :+1:")
+	assert.True(t, isCommand)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a:+1:b", "looks good 👍"}, got)
+}
+
+func TestExecuteArchiveSearchWithoutTerm(t *testing.T) {
+	isCommand, err := executeCommand(nil, "/search -a ")
+	assert.True(t, isCommand)
+	assert.EqualError(t, err, "missing argument <term>, usage: /search -a <term>")
+}
+
+func TestParseCommandArgs(t *testing.T) {
+	spec := []commandArg{
+		{name: "index", required: true, validate: func(value string) error {
+			if value == "bad" {
+				return errors.New("bad value")
+			}
+			return nil
+		}},
+		{name: "text", rest: true},
+	}
+
+	tests := []struct {
+		name     string
+		argument string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "all arguments", argument: "042 hello  world", want: []string{"042", "hello  world"}},
+		{name: "optional missing", argument: "042", want: []string{"042", ""}},
+		{name: "required missing", argument: "", wantErr: true},
+		{name: "invalid", argument: "bad hello", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCommandArgs(spec, tt.argument)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := parseCommandArgs([]commandArg{{name: "color"}}, "#fff #000")
+	assert.Error(t, err)
+}
+
+func TestCompleteCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   string
+		wantOk bool
+	}{
+		{name: "unique command", text: "/men", want: "/mentions ", wantOk: true},
+		{name: "ambiguous command", text: "/s", want: "/s", wantOk: false},
+		{name: "argument", text: "/help /ex", want: "/help /export ", wantOk: true},
+		{name: "no completion for argument", text: "/search bu", want: "/search bu", wantOk: false},
+		{name: "no command", text: "hello", want: "hello", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, ok := completeCommand(tt.text)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSlashCommand_Usage(t *testing.T) {
+	command, ok := commands.lookup("/quote")
+	assert.True(t, ok)
+	assert.Equal(t, "/q <index> <text>", command.usage())
+
+	command, ok = commands.lookup("/search")
+	assert.True(t, ok)
+	assert.Equal(t, "/search [term]", command.usage())
+}
+
+func TestValidateMessageIndex(t *testing.T) {
+	assert.Error(t, validateMessageIndex("42"))
+	assert.Error(t, validateMessageIndex("abc"))
+	// no messages loaded
+	assert.Error(t, validateMessageIndex("000"))
+}
@@ -0,0 +1,148 @@
+// main package
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+const composerPageName = "composer"
+
+// composerDraft keeps the text of a composer closed with Escape until it is opened again
+var composerDraft string
+
+// pasteInputField is the single-line input field, pasting text with line breaks into it
+// opens the multi-line composer instead of flattening the text.
+type pasteInputField struct {
+	*tview.InputField
+	onMultilinePaste func(text string)
+}
+
+// PasteHandler returns the handler for bracketed paste events.
+func (pif *pasteInputField) PasteHandler() func(pastedText string, setFocus func(p tview.Primitive)) {
+	return pif.WrapPasteHandler(func(pastedText string, setFocus func(p tview.Primitive)) {
+		if strings.ContainsAny(pastedText, "\r\n") && pif.onMultilinePaste != nil {
+			pif.onMultilinePaste(normalizeNewlines(pastedText))
+			return
+		}
+		pif.InputField.PasteHandler()(pastedText, setFocus)
+	})
+}
+
+// normalizeNewlines replaces Windows and old Mac line endings with \n.
+func normalizeNewlines(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
+
+// maxContinuationIndent is the widest indentation of continuation lines, messages with a longer
+// prefix are indented by the margin instead
+const maxContinuationIndent = 40
+
+// continuationIndent returns the indentation lining up continuation lines below the text following
+// the prefix. The prefix is measured in terminal cells, so wide characters like emoji or CJK in
+// usernames keep the alignment.
+func continuationIndent(prefix string) string {
+	width := runewidth.StringWidth(prefix)
+	if width > maxContinuationIndent {
+		return margin
+	}
+	return strings.Repeat(" ", width)
+}
+
+// indentContinuationLines indents every line but the first, so multi-line messages line up
+// below the message text in the chat view.
+func indentContinuationLines(text string, indent string) string {
+	return strings.ReplaceAll(text, "\n", "\n"+indent)
+}
+
+// generateComposerTitle returns the composer border title including the size of the text.
+func generateComposerTitle(text string) string {
+	lines := strings.Count(text, "\n") + 1
+	if lines == 1 {
+		return fmt.Sprintf(" compose - %d chars ", len([]rune(text)))
+	}
+	return fmt.Sprintf(" compose - %d lines, %d chars ", lines, len([]rune(text)))
+}
+
+// formatComposerPreview renders the text as it will appear in the chat view.
+func formatComposerPreview(text string) string {
+	username := getThisClientUsername()
+	prefix := fmt.Sprintf("[%s]%s:[-] ", getDisplayColor(envVars.ID), tview.Escape(username))
+	usernames := getClientUsernames()
+	rendered := renderMarkdown(text, func(text string) string {
+		return linkifyURLs(text, func(text string) string {
+			return highlightMentions(text, usernames, username)
+		})
+	})
+	return prefix + indentContinuationLines(rendered, continuationIndent(username+": "))
+}
+
+// showComposer opens the multi-line composer with the given text, or the last draft if the text is empty.
+// Enter inserts a line break, Ctrl+S sends, Ctrl+P toggles the preview and Escape keeps the text as draft.
+func (app *app) showComposer(text string) {
+	if text == "" {
+		text = composerDraft
+	}
+	composerDraft = ""
+
+	preview := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(true)
+	preview.SetBorder(true).SetTitle(" preview ")
+
+	textArea := tview.NewTextArea().
+		SetPlaceholder("Enter adds a line, Ctrl+S sends, Ctrl+P toggles the preview, Escape closes")
+	textArea.SetBorder(true).SetTitle(generateComposerTitle(text))
+	textArea.SetText(text, true)
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(textArea, 0, 1, true)
+
+	showPreview := false
+	textArea.SetChangedFunc(func() {
+		textArea.SetTitle(generateComposerTitle(textArea.GetText()))
+		if showPreview {
+			preview.SetText(formatComposerPreview(textArea.GetText()))
+		}
+	})
+
+	textArea.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyCtrlS,
+			event.Key() == tcell.KeyEnter && event.Modifiers()&tcell.ModAlt != 0:
+			composed := strings.TrimRight(textArea.GetText(), "\n ")
+			if strings.TrimSpace(composed) == "" {
+				return nil
+			}
+			if err := app.submitInput(composed); err != nil {
+				textArea.SetTitle(" " + err.Error() + " ")
+				return nil
+			}
+			app.hideOverlay(composerPageName)
+			return nil
+		case event.Key() == tcell.KeyCtrlP:
+			showPreview = !showPreview
+			if showPreview {
+				preview.SetText(formatComposerPreview(textArea.GetText()))
+				layout.AddItem(preview, 0, 1, false)
+			} else {
+				layout.RemoveItem(preview)
+			}
+			return nil
+		case event.Key() == tcell.KeyEscape:
+			composerDraft = textArea.GetText()
+			app.hideOverlay(composerPageName)
+			return nil
+		}
+		return event
+	})
+
+	app.showOverlay(composerPageName, layout)
+	app.ui.SetFocus(textArea)
+}
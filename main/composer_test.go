@@ -0,0 +1,63 @@
+// main package
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeNewlines(t *testing.T) {
+	assert.Equal(t, "a\nb\nc\n", normalizeNewlines("a\r\nb\rc\n"))
+}
+
+func TestIndentContinuationLines(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "single line", text: "hello", want: "hello"},
+		{name: "multiple lines", text: "func main() {\n}", want: "func main() {\n" + margin + "}"},
+		{name: "empty line", text: "a\n\nb", want: "a\n" + margin + "\n" + margin + "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, indentContinuationLines(tt.text, margin))
+		})
+	}
+}
+
+func TestContinuationIndent(t *testing.T) {
+	assert.Equal(t, "       ", continuationIndent("Alice: "))
+	// emoji and CJK characters take two cells
+	assert.Equal(t, "        ", continuationIndent("Bob 🎉: "))
+	assert.Equal(t, "      ", continuationIndent("李雷: "))
+	assert.Equal(t, margin, continuationIndent(strings.Repeat("x", maxContinuationIndent+1)))
+}
+
+func TestGenerateComposerTitle(t *testing.T) {
+	assert.Equal(t, " compose - 0 chars ", generateComposerTitle(""))
+	assert.Equal(t, " compose - 5 chars ", generateComposerTitle("héllo"))
+	assert.Equal(t, " compose - 2 lines, 3 chars ", generateComposerTitle("a\nb"))
+}
+
+func TestPasteInputField_PasteHandler(t *testing.T) {
+	var pasted string
+	input := &pasteInputField{
+		InputField:       tview.NewInputField(),
+		onMultilinePaste: func(text string) { pasted = text },
+	}
+	setFocus := func(tview.Primitive) {}
+
+	input.PasteHandler()("single line", setFocus)
+	assert.Equal(t, "single line", input.GetText())
+	assert.Equal(t, "", pasted)
+
+	input.PasteHandler()("panic: oops\r\n\tmain.go:12", setFocus)
+	assert.Equal(t, "single line", input.GetText())
+	assert.Equal(t, "panic: oops\n\tmain.go:12", pasted)
+}
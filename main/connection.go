@@ -5,9 +5,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
-	"os"
-	"os/signal"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -15,6 +14,9 @@ import (
 
 type payloadType int
 
+// writeMutex serializes the writes to the websocket, which are done by the UI, the reader and timers
+var writeMutex sync.Mutex
+
 const (
 	authenticationTypeConst  payloadType = 0
 	messageTypeConst         payloadType = 1
@@ -23,20 +25,63 @@ const (
 	typingIndicatorTypeConst payloadType = 5
 	clientTypingConst        payloadType = 6
 	getMessagesTypeConst     payloadType = 7
+	messageAckTypeConst      payloadType = 8
+	readReceiptTypeConst     payloadType = 9
 )
 
 func handlePayloadsOfMessageType(message []byte, app *app) {
 	messagePayload, err := unmarshallPayloadToMessagePayload(message)
 	if err != nil {
-		fmt.Println("Error parsing messagePayload:", err)
+		slog.Error("parsing messagePayload failed", "err", err)
 		if err := app.notifier.Notify("Error", "Error parsing messagePayload", ""); err != nil {
-			log.Fatalf("Error sending desktop notification for error parsing messagePayload: %v", err)
+			slog.Warn("sending desktop notification for error parsing messagePayload failed", "err", err)
+		}
+		return
+	}
+
+	if err := app.archive.store(messagePayload); err != nil {
+		slog.Warn("archiving messagePayload failed", "err", err)
+	}
+
+	// the server echoes messages of this client once it stored them
+	if messagePayload.ClientType.ClientDbID == envVars.ID {
+		markDelivered(messagePayload.MessageType.MessageDbID)
+	}
+
+	// known messages replace the cached ones. Edited and deleted messages render the chat view again,
+	// for echoes of sent messages and changed reactions only the message itself is rendered again.
+	if cached, isCached := getMessageByDbIDFromCache(messagePayload.MessageType.MessageDbID); isCached {
+		replaceMessageInCache(messagePayload)
+		if isSameMessageText(cached, messagePayload) {
+			app.redrawMessage(messagePayload.MessageType.MessageDbID)
+		} else {
+			app.refreshMessages()
 		}
 		return
 	}
 
 	index := appendMessageToCache(messagePayload)
+
+	// replies only change the number of replies below their parent and the open thread pane
+	if parentIndex := getThreadParentIndex(getMessagesFromCache(), messagePayload); parentIndex >= 0 {
+		if messagePayload.ClientType.ClientDbID == envVars.ID {
+			app.markAllAsRead()
+		}
+		app.redrawMessages(parentIndex)
+
+		handleDesktopNotificationPossibility(messagePayload, app)
+		return
+	}
+
+	// own messages mark everything as read, messages of others are unread until then
+	if messagePayload.ClientType.ClientDbID == envVars.ID {
+		app.markAllAsRead()
+	} else if addUnreadMessage() {
+		writeUnreadDivider()
+	}
+
 	addNewMessageToScrollPanel(&index, &messagePayload)
+	app.updateUnreadIndicators()
 
 	handleDesktopNotificationPossibility(messagePayload, app)
 }
@@ -47,18 +92,21 @@ func handleDesktopNotificationPossibility(messagePayload messagePayload, app *ap
 		return
 	}
 
-	// send desktop notification
-	if err := app.desktopNotification(&messagePayload); err != nil {
-		fmt.Println("Error sending desktop notification for received message:", err)
+	// replies are only notified if they belong to a thread of this client
+	if getThreadParentIndex(getMessagesFromCache(), messagePayload) >= 0 &&
+		!isReplyToOwnMessage(getMessagesFromCache(), messagePayload) {
 		return
 	}
+
+	// queue desktop notification
+	app.desktopNotification(&messagePayload)
 }
 
 func unmarshallPayloadToMessagePayload(message []byte) (messagePayload, error) {
 	var messagePayload messagePayload
 
 	if err := json.Unmarshal(message, &messagePayload); err != nil {
-		fmt.Println("Error parsing messagePayload:", err)
+		slog.Error("parsing messagePayload failed", "err", err)
 		return messagePayload, err
 	}
 	return messagePayload, nil
@@ -67,19 +115,116 @@ func unmarshallPayloadToMessagePayload(message []byte) (messagePayload, error) {
 func handlePayloadsOfMessageListType(message []byte, app *app) {
 	messageListPayload := unmarshallMessageToMessageListPayload(message)
 
+	for _, payload := range messageListPayload.MessageList {
+		if err := app.archive.store(payload); err != nil {
+			slog.Warn("archiving messagePayload failed", "err", err)
+		}
+	}
+
+	// messages still being sent stay below the loaded ones
+	app.showMessageList(withUndeliveredMessages(messageListPayload.MessageList))
+}
+
+func handlePayloadsOfMessageAckType(message []byte, app *app) {
+	var ackPayload messageAckPayload
+	if err := json.Unmarshal(message, &ackPayload); err != nil {
+		slog.Error("parsing messageAckPayload failed", "err", err)
+		return
+	}
+
+	if applyMessageAck(ackPayload) {
+		app.redrawMessage(ackPayload.MessageDbID)
+	}
+}
+
+// applyMessageAck marks the acknowledged message as delivered, or as failed if the server could
+// not store it. It returns false if the delivery state did not change.
+func applyMessageAck(ackPayload messageAckPayload) bool {
+	if ackPayload.Error != "" {
+		slog.Warn("server could not store message", "messageDbId", ackPayload.MessageDbID, "err", ackPayload.Error)
+		return markDeliveryFailed(ackPayload.MessageDbID)
+	}
+	return markDelivered(ackPayload.MessageDbID)
+}
+
+// handleAcknowledgement only updates the delivery state for an acknowledgement or an echo of an
+// own message. It is used while quitting, when the GUI does not handle payloads anymore.
+func handleAcknowledgement(message []byte) {
+	var msg genericMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		slog.Error("parsing JSON failed", "err", err)
+		return
+	}
+
+	switch msg.PayloadType {
+	case messageTypeConst:
+		payload, err := unmarshallPayloadToMessagePayload(message)
+		if err == nil && payload.ClientType.ClientDbID == envVars.ID {
+			markDelivered(payload.MessageType.MessageDbID)
+		}
+	case messageAckTypeConst:
+		var ackPayload messageAckPayload
+		if err := json.Unmarshal(message, &ackPayload); err != nil {
+			slog.Error("parsing messageAckPayload failed", "err", err)
+			return
+		}
+		applyMessageAck(ackPayload)
+	}
+}
+
+func handlePayloadsOfReadReceiptType(message []byte, app *app) {
+	var receiptPayload readReceiptPayload
+	if err := json.Unmarshal(message, &receiptPayload); err != nil {
+		slog.Error("parsing readReceiptPayload failed", "err", err)
+		return
+	}
+
+	if receiptPayload.ClientDbID == envVars.ID {
+		return
+	}
+
+	// the receipts are stored in any case, but only shown if enabled. They only change the status
+	// below own messages.
+	if setReadReceipt(receiptPayload.ClientDbID, receiptPayload.MessageDbID) && getEnvReadReceipts() {
+		var ownIndices []int
+		for index, payload := range getMessagesFromCache() {
+			if payload.ClientType.ClientDbID == envVars.ID {
+				ownIndices = append(ownIndices, index)
+			}
+		}
+		app.redrawMessages(ownIndices...)
+	}
+}
+
+// showMessageList replaces the cached and displayed messages with the given list.
+func (app *app) showMessageList(messageList []messagePayload) {
 	resetMessageCache()
 	app.clearChatView()
 
-	for _, payload := range messageListPayload.MessageList {
-		index := appendMessageToCache(payload)
-		addNewMessageToScrollPanel(&index, &payload)
+	firstUnread := resetUnread(messageList)
+
+	// all messages are cached first, so parents know the number of their replies
+	for _, payload := range messageList {
+		appendMessageToCache(payload)
+	}
+
+	// replayed history never triggers desktop notifications
+	for i := range messageList {
+		if i == firstUnread {
+			writeUnreadDivider()
+		}
+
+		index := i
+		addNewMessageToScrollPanel(&index, &messageList[i])
 	}
+
+	app.updateUnreadIndicators()
 }
 
 func unmarshallMessageToMessageListPayload(message []byte) messageListPayload {
 	var messageListPayload messageListPayload
 	if err := json.Unmarshal(message, &messageListPayload); err != nil {
-		fmt.Println("Error parsing messageListPayload:", err)
+		slog.Error("parsing messageListPayload failed", "err", err)
 	}
 	return messageListPayload
 }
@@ -87,7 +232,7 @@ func unmarshallMessageToMessageListPayload(message []byte) messageListPayload {
 func handlePayloadsOfTypingIndicatorType(message []byte, app *app) {
 	var typingPayload typingPayload
 	if err := json.Unmarshal(message, &typingPayload); err != nil {
-		fmt.Println("Error parsing typingPayload:", err)
+		slog.Error("parsing typingPayload failed", "err", err)
 	}
 
 	if typingPayload.IsTyping {
@@ -96,29 +241,35 @@ func handlePayloadsOfTypingIndicatorType(message []byte, app *app) {
 		removeTypingClient(typingPayload.ClientDbID)
 	}
 
+	renderSidebar()
+
 	typingLabelText := generateTypingString()
-	fmt.Println(typingLabelText)
+	slog.Debug("typing", "label", typingLabelText)
 	app.setTypingLabelText(typingLabelText)
 }
 
 func handlePayloadsOfClientListType(message []byte, app *app) {
 	clientListPayload, err := unmarshallMessageToClientListPayload(message)
 	if err != nil {
-		fmt.Println("Error parsing clientListPayload:", err)
+		slog.Error("parsing clientListPayload failed", "err", err)
 		if err := app.notifier.Notify("Error", "Error parsing clientListPayload", ""); err != nil {
-			log.Fatalf("Error sending desktop notification for error parsing clientListPayload: %v", err)
+			slog.Warn("sending desktop notification for error parsing clientListPayload failed", "err", err)
 		}
 		return
 	}
 
 	setClientList(&clientListPayload)
+	renderSidebar()
+	if err := saveClientList(&clientListPayload); err != nil {
+		slog.Warn("saving clientList failed", "err", err)
+	}
 	retrieveLast100Messages(app.conn)
 }
 
 func unmarshallMessageToClientListPayload(message []byte) (clientListStruct, error) {
 	var clientListPayload clientListStruct
 	if err := json.Unmarshal(message, &clientListPayload); err != nil {
-		fmt.Println("Error parsing clientListPayload:", err)
+		slog.Error("parsing clientListPayload failed", "err", err)
 		return clientListPayload, err
 	}
 	return clientListPayload, nil
@@ -127,7 +278,7 @@ func unmarshallMessageToClientListPayload(message []byte) (clientListStruct, err
 func handlePayload(message []byte, app *app) {
 	var msg genericMessage
 	if err := json.Unmarshal(message, &msg); err != nil {
-		fmt.Println("Error parsing JSON:", err)
+		slog.Error("parsing JSON failed", "err", err)
 		return
 	}
 
@@ -148,78 +299,109 @@ func handlePayload(message []byte, app *app) {
 	case getMessagesTypeConst:
 		retrieveLast100Messages(app.conn)
 
+	case messageAckTypeConst:
+		handlePayloadsOfMessageAckType(message, app)
+
+	case readReceiptTypeConst:
+		handlePayloadsOfReadReceiptType(message, app)
+
 	default:
-		fmt.Println("unknown PayloadType", msg.PayloadType)
+		slog.Warn("unknown payloadType", "payloadType", msg.PayloadType)
 	}
 
-	app.ui.Draw()
+	dispatchToPlugins(msg.PayloadType, message, app)
 }
 
-func connection(app *app) error {
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-	defer func(c *websocket.Conn) {
-		err := c.Close()
+// connection reads the payloads of the server until the connection is closed. They are handled on
+// the UI goroutine, which owns the views and the state they show. While quitting only the delivery
+// state is updated, see shutdown. A connection closed while localterm is not quitting ends it.
+func connection(app *app) {
+	for {
+		_, message, err := app.conn.ReadMessage()
 		if err != nil {
-			log.Fatal("close:", err)
-		}
-	}(app.conn)
-	done := make(chan struct{})
-	go func() {
-		defer close(done)
-		for {
-			_, message, err := app.conn.ReadMessage()
-			if err != nil {
-				log.Println("read:", err)
-				return
+			if app.ctx.Err() == nil {
+				app.quit(fmt.Errorf("%w: %v", errConnectionLost, err))
 			}
+			return
+		}
 
-			handlePayload(message, app)
+		logFrame("received", message)
+		if app.ctx.Err() != nil {
+			handleAcknowledgement(message)
+			continue
 		}
-	}()
-	for {
+		app.ui.QueueUpdateDraw(func() {
+			handlePayload(message, app)
+		})
+	}
+}
+
+// closeConnection closes the websocket with a normal closure. It waits until the server closed
+// the connection, at most for the timeout, before the connection is closed anyway.
+func (app *app) closeConnection(done <-chan struct{}, timeout time.Duration) {
+	err := writeMessage(app.conn, websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	if err != nil {
+		slog.Error("writing close message failed", "err", err)
+	} else {
 		select {
 		case <-done:
-			return nil
-		case <-interrupt:
-			log.Println("interrupt")
-			// Clean disconnect
-			err := app.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-			if err != nil {
-				log.Println("write close:", err)
-				return nil
-			}
-			// Wait for the server to close the connection after sending the close message
-			select {
-			case <-done:
-			case <-time.After(time.Second):
-			}
-			return nil
+		case <-time.After(timeout):
 		}
 	}
+
+	if err := app.conn.Close(); err != nil {
+		slog.Error("closing connection failed", "err", err)
+	}
 }
 
-func sendMessagePayloadToWebsocket(conn *websocket.Conn, message *string) {
-	messagePayload := messagePayload{
+// newMessagePayload builds the payload of a plain message of this client
+func newMessagePayload(message string) messagePayload {
+	timestamp, date, clock := newTimestamps(time.Now())
+	return messagePayload{
 		PayloadType: messageTypeConst,
 		MessageType: messageType{
 			MessageDbID:    GenerateRandomID(),
-			MessageContext: base64.StdEncoding.EncodeToString([]byte(*message)),
+			MessageContext: base64.StdEncoding.EncodeToString([]byte(message)),
 			Deleted:        false,
 			Edited:         false,
-			MessageTime:    time.Now().Format("15:04"),
-			MessageDate:    time.Now().Format("2006-01-02"),
+			MessageTime:    clock,
+			MessageDate:    date,
+			Timestamp:      timestamp,
 		},
 		ClientType: clientType{
 			ClientDbID: envVars.ID,
 		},
 	}
+}
 
-	// Send the message
-	err := conn.WriteJSON(messagePayload)
+// newTypingPayload builds the payload telling the other clients whether this client is typing
+func newTypingPayload(isTyping bool) typingPayload {
+	return typingPayload{
+		PayloadType: typingIndicatorTypeConst,
+		ClientDbID:  envVars.ID,
+		IsTyping:    isTyping,
+	}
+}
+
+// writeJSON writes the payload to the websocket, with --debug the frame is logged. All writes go
+// through writeJSON or writeMessage, the websocket only supports one writer at a time.
+func writeJSON(conn *websocket.Conn, payload any) error {
+	frame, err := json.Marshal(payload)
 	if err != nil {
-		fmt.Println("Error writing messagePayload:", err)
+		return err
+	}
+	return writeMessage(conn, websocket.TextMessage, frame)
+}
+
+// writeMessage writes a frame of the given message type to the websocket, see writeJSON.
+func writeMessage(conn *websocket.Conn, messageType int, data []byte) error {
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+
+	if messageType == websocket.TextMessage {
+		logFrame("sent", data)
 	}
+	return conn.WriteMessage(messageType, data)
 }
 
 func retrieveLast100Messages(c *websocket.Conn) {
@@ -228,8 +410,8 @@ func retrieveLast100Messages(c *websocket.Conn) {
 		PayloadType: messageListTypeConst,
 	}
 
-	if err := c.WriteJSON(messageListPayload); err != nil {
-		fmt.Println("Error writing messageListPayload:", err)
+	if err := writeJSON(c, messageListPayload); err != nil {
+		slog.Error("writing messageListPayload failed", "err", err)
 	}
 }
 
@@ -245,7 +427,7 @@ func authenticateClientAtSocket(c *websocket.Conn) error {
 		}
 		return nil
 	}
-	return writeError(c.WriteMessage(websocket.TextMessage, authenticationPayloadBytes))
+	return writeError(writeMessage(c, websocket.TextMessage, authenticationPayloadBytes))
 }
 
 func getAuthenticationPayloadBytes() ([]byte, error) {
@@ -0,0 +1,389 @@
+// main package
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// ackTimeout is how long a sent message waits for the acknowledgement of the server before it is
+// shown as not delivered
+const ackTimeout = 10 * time.Second
+
+type deliveryState int
+
+const (
+	deliveryPending deliveryState = iota
+	deliveryDelivered
+	deliveryFailed
+)
+
+type outboxEntry struct {
+	payload messagePayload
+	state   deliveryState
+	timer   *time.Timer
+}
+
+var (
+	outboxMutex sync.Mutex
+	// outbox holds the messages of this client the server did not acknowledge yet, in the order they were sent
+	outbox []*outboxEntry
+
+	receiptMutex sync.Mutex
+	// readReceipts maps the id of a client to the MessageDbID of the last message it has read
+	readReceipts = make(map[string]string)
+	// lastSentReceiptID is the MessageDbID of the last read receipt sent by this client
+	lastSentReceiptID string
+)
+
+// findOutboxEntry returns the outbox entry of the message, outboxMutex has to be held.
+func findOutboxEntry(messageDbID string) *outboxEntry {
+	for _, entry := range outbox {
+		if entry.payload.MessageType.MessageDbID == messageDbID {
+			return entry
+		}
+	}
+	return nil
+}
+
+// trackDelivery adds the message to the outbox as pending. If the server does not acknowledge it
+// within the timeout, it is marked as failed and onFailed is called.
+func trackDelivery(payload messagePayload, timeout time.Duration, onFailed func()) {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	messageDbID := payload.MessageType.MessageDbID
+	entry := findOutboxEntry(messageDbID)
+	if entry == nil {
+		entry = &outboxEntry{payload: payload}
+		outbox = append(outbox, entry)
+	} else if entry.timer != nil {
+		entry.timer.Stop()
+	}
+
+	entry.state = deliveryPending
+	entry.timer = time.AfterFunc(timeout, func() {
+		if markDeliveryFailed(messageDbID) && onFailed != nil {
+			onFailed()
+		}
+	})
+}
+
+// markDelivered removes the message from the outbox. It returns false if the message was not
+// waiting for an acknowledgement.
+func markDelivered(messageDbID string) bool {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	for i, entry := range outbox {
+		if entry.payload.MessageType.MessageDbID == messageDbID {
+			if entry.timer != nil {
+				entry.timer.Stop()
+			}
+			outbox = append(outbox[:i], outbox[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// markDeliveryFailed marks a pending message as not delivered. It returns false if the message is
+// not pending.
+func markDeliveryFailed(messageDbID string) bool {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	entry := findOutboxEntry(messageDbID)
+	if entry == nil || entry.state != deliveryPending {
+		return false
+	}
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+	entry.state = deliveryFailed
+	return true
+}
+
+// getDeliveryState returns the delivery state of the message, messages not in the outbox are delivered.
+func getDeliveryState(messageDbID string) deliveryState {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	if entry := findOutboxEntry(messageDbID); entry != nil {
+		return entry.state
+	}
+	return deliveryDelivered
+}
+
+// getUndeliveredMessages returns the pending and failed messages of the outbox in the order they were sent.
+func getUndeliveredMessages() []messagePayload {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	messages := make([]messagePayload, 0, len(outbox))
+	for _, entry := range outbox {
+		messages = append(messages, entry.payload)
+	}
+	return messages
+}
+
+// getFailedMessages returns the messages which were not delivered in the order they were sent.
+func getFailedMessages() []messagePayload {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	var messages []messagePayload
+	for _, entry := range outbox {
+		if entry.state == deliveryFailed {
+			messages = append(messages, entry.payload)
+		}
+	}
+	return messages
+}
+
+// waitForPendingMessages waits until no message is waiting for an acknowledgement anymore, at most
+// for the timeout. It returns false if messages are still pending.
+func waitForPendingMessages(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if !hasPendingMessages() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// hasPendingMessages reports whether messages are waiting for an acknowledgement.
+func hasPendingMessages() bool {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	for _, entry := range outbox {
+		if entry.state == deliveryPending {
+			return true
+		}
+	}
+	return false
+}
+
+// resetOutbox stops waiting for all acknowledgements and empties the outbox.
+func resetOutbox() {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	for _, entry := range outbox {
+		if entry.timer != nil {
+			entry.timer.Stop()
+		}
+	}
+	outbox = nil
+}
+
+// withUndeliveredMessages marks the messages of the list as delivered and appends the messages of
+// the outbox missing in it, so reloading the messages keeps those still being sent.
+func withUndeliveredMessages(messageList []messagePayload) []messagePayload {
+	for _, payload := range messageList {
+		if payload.ClientType.ClientDbID == envVars.ID {
+			markDelivered(payload.MessageType.MessageDbID)
+		}
+	}
+	return append(messageList, getUndeliveredMessages()...)
+}
+
+// setReadReceipt stores the last message read by the client. It returns false if it did not change.
+func setReadReceipt(clientID string, messageDbID string) bool {
+	receiptMutex.Lock()
+	defer receiptMutex.Unlock()
+
+	if readReceipts[clientID] == messageDbID {
+		return false
+	}
+	readReceipts[clientID] = messageDbID
+	return true
+}
+
+// resetReadReceipts forgets the read receipts of all clients.
+func resetReadReceipts() {
+	receiptMutex.Lock()
+	defer receiptMutex.Unlock()
+
+	readReceipts = make(map[string]string)
+	lastSentReceiptID = ""
+}
+
+// getSeenBy returns the sorted usernames of the clients which have read the message at index. A
+// client is only listed below the latest message of ownID it has read, replies are left out.
+func getSeenBy(messages []messagePayload, index int, ownID string) []string {
+	receiptMutex.Lock()
+	receipts := make(map[string]string, len(readReceipts))
+	for clientID, messageDbID := range readReceipts {
+		receipts[clientID] = messageDbID
+	}
+	receiptMutex.Unlock()
+
+	positions := make(map[string]int, len(messages))
+	for i, payload := range messages {
+		positions[payload.MessageType.MessageDbID] = i
+	}
+
+	var seenBy []string
+	for clientID, messageDbID := range receipts {
+		readIndex, found := positions[messageDbID]
+		if clientID == ownID || !found || readIndex < index {
+			continue
+		}
+
+		latestOwn := -1
+		for i := readIndex; i >= 0; i-- {
+			if messages[i].ClientType.ClientDbID == ownID && messages[i].MessageType.ParentMessageDbID == "" {
+				latestOwn = i
+				break
+			}
+		}
+		if latestOwn == index {
+			seenBy = append(seenBy, getUsernameForID(clientID))
+		}
+	}
+
+	sort.Strings(seenBy)
+	return seenBy
+}
+
+// formatDeliveryStatus returns the delivery state shown behind own messages: pending, not delivered
+// with the command to send it again, or delivered together with the clients which have read it.
+func formatDeliveryStatus(index int, payload messagePayload) string {
+	if payload.ClientType.ClientDbID != envVars.ID || payload.MessageType.Deleted {
+		return ""
+	}
+
+	markers := getMarkers()
+	switch getDeliveryState(payload.MessageType.MessageDbID) {
+	case deliveryPending:
+		return " " + colorTag(currentTheme.Muted) + markers.pending + "[-]"
+	case deliveryFailed:
+		return fmt.Sprintf(" %s%s, /retry %03d[-]", colorTag(currentTheme.Error), markers.failed, index)
+	}
+
+	status := markers.delivered
+	if getEnvReadReceipts() {
+		if seenBy := getSeenBy(getMessagesFromCache(), index, envVars.ID); len(seenBy) > 0 {
+			status = strings.TrimSpace(status + " seen by " + tview.Escape(strings.Join(seenBy, ", ")))
+		}
+	}
+	if status == "" {
+		return ""
+	}
+	return " " + colorTag(currentTheme.Muted) + status + "[-]"
+}
+
+// newReadReceiptPayload builds the read receipt of this client for the given message.
+func newReadReceiptPayload(messageDbID string) readReceiptPayload {
+	return readReceiptPayload{
+		PayloadType: readReceiptTypeConst,
+		ClientDbID:  envVars.ID,
+		MessageDbID: messageDbID,
+	}
+}
+
+// isSameMessageText reports whether both payloads of a message have the same text and were neither
+// edited nor deleted in between, e.g. a sent message and its echo.
+func isSameMessageText(a messagePayload, b messagePayload) bool {
+	return a.MessageType.MessageContext == b.MessageType.MessageContext &&
+		a.MessageType.Edited == b.MessageType.Edited &&
+		a.MessageType.Deleted == b.MessageType.Deleted
+}
+
+// refreshMessages renders the chat view and the open thread again from the cache.
+func (app *app) refreshMessages() {
+	app.showMessageList(getMessagesFromCache())
+	renderThread()
+}
+
+// sendMessagePayload sends a new message of this client. It is shown right away as pending until
+// the server acknowledges it, and as not delivered if the acknowledgement does not arrive in time.
+func (app *app) sendMessagePayload(payload messagePayload) error {
+	messageDbID := payload.MessageType.MessageDbID
+	trackDelivery(payload, ackTimeout, func() {
+		app.ui.QueueUpdateDraw(func() { app.redrawMessage(messageDbID) })
+	})
+
+	// the message is cached and shown before it is sent, so the echo of the server replaces it
+	index := appendMessageToCache(payload)
+	markAllAsRead()
+	if parentIndex := getThreadParentIndex(getMessagesFromCache(), payload); parentIndex >= 0 {
+		app.redrawMessages(parentIndex)
+	} else {
+		addNewMessageToScrollPanel(&index, &payload)
+	}
+	app.updateUnreadIndicators()
+
+	if err := writeJSON(app.conn, payload); err != nil {
+		markDeliveryFailed(messageDbID)
+		app.redrawMessage(messageDbID)
+		return err
+	}
+
+	app.sendReadReceipt()
+	return nil
+}
+
+// retryMessage sends a message which was not delivered again.
+func (app *app) retryMessage(payload messagePayload) error {
+	messageDbID := payload.MessageType.MessageDbID
+	if getDeliveryState(messageDbID) != deliveryFailed {
+		return errors.New("only messages marked as not delivered can be sent again")
+	}
+
+	trackDelivery(payload, ackTimeout, func() {
+		app.ui.QueueUpdateDraw(func() { app.redrawMessage(messageDbID) })
+	})
+	err := writeJSON(app.conn, payload)
+	if err != nil {
+		markDeliveryFailed(messageDbID)
+	}
+	app.redrawMessage(messageDbID)
+
+	return err
+}
+
+// markAllAsRead marks all messages as read and sends a read receipt if they are enabled.
+func (app *app) markAllAsRead() {
+	markAllAsRead()
+	app.sendReadReceipt()
+}
+
+// sendReadReceipt tells the other clients the last message this client has read, if read receipts
+// are enabled and it changed since the last receipt.
+func (app *app) sendReadReceipt() {
+	if !getEnvReadReceipts() {
+		return
+	}
+
+	unreadMutex.Lock()
+	lastReadID := lastReadMessageID
+	unreadMutex.Unlock()
+
+	receiptMutex.Lock()
+	changed := lastReadID != "" && lastReadID != lastSentReceiptID
+	if changed {
+		lastSentReceiptID = lastReadID
+	}
+	receiptMutex.Unlock()
+
+	if !changed {
+		return
+	}
+	if err := writeJSON(app.conn, newReadReceiptPayload(lastReadID)); err != nil {
+		slog.Error("writing readReceiptPayload failed", "err", err)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryStates(t *testing.T) {
+	t.Cleanup(resetOutbox)
+
+	first := newTestMessagePayload(envVars.ID, "first")
+	second := newTestMessagePayload(envVars.ID, "second")
+
+	trackDelivery(first, time.Hour, nil)
+	trackDelivery(second, time.Hour, nil)
+	assert.Equal(t, deliveryPending, getDeliveryState(first.MessageType.MessageDbID))
+	assert.Equal(t, []messagePayload{first, second}, getUndeliveredMessages())
+
+	assert.True(t, markDeliveryFailed(second.MessageType.MessageDbID))
+	assert.False(t, markDeliveryFailed(second.MessageType.MessageDbID))
+	assert.Equal(t, deliveryFailed, getDeliveryState(second.MessageType.MessageDbID))
+	assert.Equal(t, []messagePayload{second}, getFailedMessages())
+
+	assert.True(t, markDelivered(first.MessageType.MessageDbID))
+	assert.False(t, markDelivered(first.MessageType.MessageDbID))
+	assert.Equal(t, deliveryDelivered, getDeliveryState(first.MessageType.MessageDbID))
+
+	// retrying makes a failed message pending again
+	trackDelivery(second, time.Hour, nil)
+	assert.Equal(t, deliveryPending, getDeliveryState(second.MessageType.MessageDbID))
+	assert.Empty(t, getFailedMessages())
+	assert.Equal(t, []messagePayload{second}, getUndeliveredMessages())
+
+	// unknown messages were delivered
+	assert.Equal(t, deliveryDelivered, getDeliveryState("unknown"))
+}
+
+func TestTrackDelivery_Timeout(t *testing.T) {
+	t.Cleanup(resetOutbox)
+
+	payload := newTestMessagePayload(envVars.ID, "lost")
+	failed := make(chan struct{})
+	trackDelivery(payload, time.Millisecond, func() { close(failed) })
+
+	select {
+	case <-failed:
+	case <-time.After(time.Second):
+		t.Fatal("message was not marked as failed")
+	}
+	assert.Equal(t, deliveryFailed, getDeliveryState(payload.MessageType.MessageDbID))
+}
+
+func TestTrackDelivery_AcknowledgedInTime(t *testing.T) {
+	t.Cleanup(resetOutbox)
+
+	payload := newTestMessagePayload(envVars.ID, "stored")
+	trackDelivery(payload, 20*time.Millisecond, func() { t.Error("acknowledged message was marked as failed") })
+	assert.True(t, markDelivered(payload.MessageType.MessageDbID))
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, deliveryDelivered, getDeliveryState(payload.MessageType.MessageDbID))
+}
+
+func TestWithUndeliveredMessages(t *testing.T) {
+	t.Cleanup(resetOutbox)
+
+	stored := newTestMessagePayload(envVars.ID, "stored")
+	pending := newTestMessagePayload(envVars.ID, "pending")
+	other := newTestMessagePayload("a", "other")
+	trackDelivery(stored, time.Hour, nil)
+	trackDelivery(pending, time.Hour, nil)
+
+	messages := withUndeliveredMessages([]messagePayload{stored, other})
+
+	assert.Equal(t, []messagePayload{stored, other, pending}, messages)
+	assert.Equal(t, deliveryDelivered, getDeliveryState(stored.MessageType.MessageDbID))
+	assert.Equal(t, deliveryPending, getDeliveryState(pending.MessageType.MessageDbID))
+}
+
+func TestGetSeenBy(t *testing.T) {
+	setTestClientList(t,
+		client{ClientDbID: "a", ClientUsername: "Alice"},
+		client{ClientDbID: "b", ClientUsername: "Bob"},
+		client{ClientDbID: "c", ClientUsername: "Carol"})
+	t.Cleanup(resetReadReceipts)
+
+	messages := []messagePayload{
+		newTestMessagePayload("me", "first"),
+		newTestMessagePayload("a", "answer"),
+		newTestMessagePayload("me", "second"),
+		newTestMessagePayload("b", "last"),
+	}
+	reply := newReplyMessagePayload(messages[2], "reply")
+	reply.ClientType.ClientDbID = "me"
+	messages = append(messages, reply)
+
+	setReadReceipt("a", messages[1].MessageType.MessageDbID)
+	setReadReceipt("b", messages[4].MessageType.MessageDbID)
+	setReadReceipt("c", messages[2].MessageType.MessageDbID)
+	setReadReceipt("me", messages[4].MessageType.MessageDbID)
+	setReadReceipt("d", "unknown")
+
+	tests := []struct {
+		name  string
+		index int
+		want  []string
+	}{
+		{name: "read up to a later message of another client", index: 0, want: []string{"Alice"}},
+		{name: "latest own message, replies are left out", index: 2, want: []string{"Bob", "Carol"}},
+		{name: "message of another client", index: 1, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getSeenBy(messages, tt.index, "me"))
+		})
+	}
+}
+
+func TestSetReadReceipt(t *testing.T) {
+	t.Cleanup(resetReadReceipts)
+
+	assert.True(t, setReadReceipt("a", "1"))
+	assert.False(t, setReadReceipt("a", "1"))
+	assert.True(t, setReadReceipt("a", "2"))
+}
+
+func TestFormatDeliveryStatus(t *testing.T) {
+	t.Cleanup(resetOutbox)
+
+	delivered := newTestMessagePayload(envVars.ID, "delivered")
+	pending := newTestMessagePayload(envVars.ID, "pending")
+	failed := newTestMessagePayload(envVars.ID, "failed")
+	other := newTestMessagePayload("a", "other")
+	trackDelivery(pending, time.Hour, nil)
+	trackDelivery(failed, time.Hour, nil)
+	markDeliveryFailed(failed.MessageType.MessageDbID)
+
+	assert.Equal(t, " [gray]✓[-]", formatDeliveryStatus(0, delivered))
+	assert.Equal(t, " [gray]⋯[-]", formatDeliveryStatus(1, pending))
+	assert.Equal(t, " [red]✗ not delivered, /retry 002[-]", formatDeliveryStatus(2, failed))
+	assert.Empty(t, formatDeliveryStatus(3, other))
+
+	plainMode = true
+	t.Cleanup(func() { plainMode = false })
+	assert.Empty(t, formatDeliveryStatus(0, delivered))
+	assert.Equal(t, " [gray]sending[-]", formatDeliveryStatus(1, pending))
+}
+
+func TestIsSameMessageText(t *testing.T) {
+	sent := newTestMessagePayload(envVars.ID, "hello")
+	echo := sent
+	echo.ReactionType = &[]reactionType{{ReactionContext: "👍", ReactionClientID: "a"}}
+	edited := sent
+	edited.MessageType.MessageContext = base64.StdEncoding.EncodeToString([]byte("hello!"))
+	edited.MessageType.Edited = true
+	deleted := sent
+	deleted.MessageType.MessageContext = ""
+	deleted.MessageType.Deleted = true
+
+	assert.True(t, isSameMessageText(sent, echo))
+	assert.False(t, isSameMessageText(sent, edited))
+	assert.False(t, isSameMessageText(sent, deleted))
+}
+
+func TestHandleAcknowledgement(t *testing.T) {
+	t.Cleanup(resetOutbox)
+
+	echoed := newTestMessagePayload(envVars.ID, "echoed")
+	acknowledged := newTestMessagePayload(envVars.ID, "acknowledged")
+	rejected := newTestMessagePayload(envVars.ID, "rejected")
+	for _, payload := range []messagePayload{echoed, acknowledged, rejected} {
+		trackDelivery(payload, time.Hour, nil)
+	}
+
+	echo, _ := json.Marshal(echoed)
+	ack, _ := json.Marshal(messageAckPayload{PayloadType: messageAckTypeConst, MessageDbID: acknowledged.MessageType.MessageDbID})
+	rejection, _ := json.Marshal(messageAckPayload{PayloadType: messageAckTypeConst, MessageDbID: rejected.MessageType.MessageDbID, Error: "disk full"})
+	for _, message := range [][]byte{echo, ack, rejection} {
+		handleAcknowledgement(message)
+	}
+
+	assert.Equal(t, deliveryDelivered, getDeliveryState(echoed.MessageType.MessageDbID))
+	assert.Equal(t, deliveryDelivered, getDeliveryState(acknowledged.MessageType.MessageDbID))
+	assert.Equal(t, deliveryFailed, getDeliveryState(rejected.MessageType.MessageDbID))
+}
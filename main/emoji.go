@@ -0,0 +1,329 @@
+// main package
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+const (
+	emojiPickerPageName = "emoji"
+	// maxRecentEmojis is the number of recently used emojis listed first in the picker
+	maxRecentEmojis = 16
+	// emojiCellWidth is the width of the emoji column of the picker, emojis are two cells wide
+	emojiCellWidth = 3
+)
+
+// emojiEntry is an emoji with its shortcode, without the surrounding colons.
+type emojiEntry struct {
+	shortcode string
+	emoji     string
+}
+
+var emojis = []emojiEntry{
+	{"+1", "👍"}, {"thumbsup", "👍"}, {"-1", "👎"}, {"thumbsdown", "👎"}, {"ok_hand", "👌"},
+	{"clap", "👏"}, {"wave", "👋"}, {"pray", "🙏"}, {"muscle", "💪"}, {"raised_hands", "🙌"},
+	{"point_up", "☝️"}, {"v", "✌️"}, {"crossed_fingers", "🤞"}, {"handshake", "🤝"}, {"eyes", "👀"},
+	{"smile", "😄"}, {"smiley", "😃"}, {"grin", "😁"}, {"laughing", "😆"}, {"joy", "😂"},
+	{"rofl", "🤣"}, {"wink", "😉"}, {"blush", "😊"}, {"slightly_smiling_face", "🙂"}, {"upside_down_face", "🙃"},
+	{"heart_eyes", "😍"}, {"kissing_heart", "😘"}, {"yum", "😋"}, {"stuck_out_tongue", "😛"}, {"sunglasses", "😎"},
+	{"thinking", "🤔"}, {"neutral_face", "😐"}, {"expressionless", "😑"}, {"roll_eyes", "🙄"}, {"smirk", "😏"},
+	{"grimacing", "😬"}, {"relieved", "😌"}, {"pensive", "😔"}, {"sleepy", "😪"}, {"sleeping", "😴"},
+	{"mask", "😷"}, {"nerd_face", "🤓"}, {"confused", "😕"}, {"worried", "😟"}, {"open_mouth", "😮"},
+	{"astonished", "😲"}, {"flushed", "😳"}, {"cry", "😢"}, {"sob", "😭"}, {"scream", "😱"},
+	{"angry", "😠"}, {"rage", "😡"}, {"skull", "💀"}, {"poop", "💩"}, {"clown_face", "🤡"},
+	{"ghost", "👻"}, {"robot", "🤖"}, {"see_no_evil", "🙈"}, {"facepalm", "🤦"}, {"shrug", "🤷"},
+	{"heart", "❤️"}, {"orange_heart", "🧡"}, {"yellow_heart", "💛"}, {"green_heart", "💚"}, {"blue_heart", "💙"},
+	{"purple_heart", "💜"}, {"broken_heart", "💔"}, {"sparkles", "✨"}, {"star", "⭐"}, {"fire", "🔥"},
+	{"100", "💯"}, {"boom", "💥"}, {"zap", "⚡"}, {"tada", "🎉"}, {"confetti_ball", "🎊"},
+	{"gift", "🎁"}, {"trophy", "🏆"}, {"rocket", "🚀"}, {"bulb", "💡"}, {"bug", "🐛"},
+	{"warning", "⚠️"}, {"x", "❌"}, {"white_check_mark", "✅"}, {"heavy_check_mark", "✔️"}, {"question", "❓"},
+	{"exclamation", "❗"}, {"no_entry", "⛔"}, {"construction", "🚧"}, {"lock", "🔒"}, {"key", "🔑"},
+	{"hammer", "🔨"}, {"wrench", "🔧"}, {"gear", "⚙️"}, {"link", "🔗"}, {"memo", "📝"},
+	{"calendar", "📅"}, {"email", "📧"}, {"bell", "🔔"}, {"mute", "🔇"}, {"computer", "💻"},
+	{"coffee", "☕"}, {"beer", "🍺"}, {"beers", "🍻"}, {"pizza", "🍕"}, {"cake", "🍰"},
+	{"sun", "☀️"}, {"cloud", "☁️"}, {"umbrella", "☔"}, {"snowflake", "❄️"}, {"rainbow", "🌈"},
+	{"dog", "🐶"}, {"cat", "🐱"}, {"unicorn", "🦄"}, {"ok", "🆗"},
+}
+
+var emojiByShortcode = make(map[string]string)
+
+func init() {
+	for _, entry := range emojis {
+		emojiByShortcode[entry.shortcode] = entry.emoji
+	}
+}
+
+var (
+	shortcodeRegex   = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+	inlineCodeRegex  = regexp.MustCompile("`[^`]*`")
+	recentEmojiMutex sync.Mutex
+	recentEmojis     []string
+)
+
+// expandShortcodes replaces known :shortcodes: like :+1: with their emoji. Inline code and
+// fenced code blocks are left untouched.
+func expandShortcodes(text string) string {
+	lines := strings.Split(text, "\n")
+	inCode := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+
+		var builder strings.Builder
+		last := 0
+		for _, loc := range inlineCodeRegex.FindAllStringIndex(line, -1) {
+			builder.WriteString(expandLineShortcodes(line[last:loc[0]]))
+			builder.WriteString(line[loc[0]:loc[1]])
+			last = loc[1]
+		}
+		builder.WriteString(expandLineShortcodes(line[last:]))
+		lines[i] = builder.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func expandLineShortcodes(text string) string {
+	return shortcodeRegex.ReplaceAllStringFunc(text, func(match string) string {
+		if emoji, ok := emojiByShortcode[strings.Trim(match, ":")]; ok {
+			return emoji
+		}
+		return match
+	})
+}
+
+// searchEmojis returns the emojis whose shortcode contains the term. An exact match is listed
+// first, followed by the shortcodes starting with the term.
+func searchEmojis(term string) []emojiEntry {
+	term = strings.ToLower(strings.Trim(term, ": "))
+
+	var found []emojiEntry
+	for _, entry := range emojis {
+		if strings.Contains(entry.shortcode, term) {
+			found = append(found, entry)
+		}
+	}
+
+	rank := func(shortcode string) int {
+		switch {
+		case shortcode == term:
+			return 0
+		case strings.HasPrefix(shortcode, term):
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return rank(found[i].shortcode) < rank(found[j].shortcode)
+	})
+
+	return found
+}
+
+// completeShortcode completes the :shortcode at the end of text to its emoji if it is unique
+// or matches a shortcode exactly. It returns false if there is nothing to complete.
+func completeShortcode(text string) (string, bool) {
+	start := strings.LastIndexAny(text, " \t") + 1
+	word := text[start:]
+	if !strings.HasPrefix(word, ":") || len(word) < 2 {
+		return text, false
+	}
+
+	shortcode := strings.TrimSuffix(word[1:], ":")
+	if emoji, ok := emojiByShortcode[shortcode]; ok {
+		return text[:start] + emoji + " ", true
+	}
+
+	var found []emojiEntry
+	for _, entry := range emojis {
+		if strings.HasPrefix(entry.shortcode, shortcode) {
+			found = append(found, entry)
+		}
+	}
+	if len(found) != 1 {
+		return text, false
+	}
+	return text[:start] + found[0].emoji + " ", true
+}
+
+// emojiWidth returns the number of cells the emoji takes in the terminal. Emojis with the
+// emoji presentation selector U+FE0F, like ❤️, are shown two cells wide by terminals although
+// their base character is narrow.
+func emojiWidth(emoji string) int {
+	width := runewidth.StringWidth(emoji)
+	if strings.ContainsRune(emoji, '\uFE0F') && width < 2 {
+		width = 2
+	}
+	return width
+}
+
+// formatEmojiEntry formats an emoji of the picker with its shortcode. Emojis are padded by their
+// display width, so the shortcodes line up.
+func formatEmojiEntry(entry emojiEntry) string {
+	return entry.emoji + strings.Repeat(" ", max(1, emojiCellWidth-emojiWidth(entry.emoji))) + ":" + entry.shortcode + ":"
+}
+
+// getRecentEmojiFilePath returns the file the recently used emojis are kept in.
+func getRecentEmojiFilePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".localchat", "emoji", "recent.txt"), nil
+}
+
+// loadRecentEmojis reads the recently used emojis, most recent first.
+func loadRecentEmojis() []string {
+	recentEmojiMutex.Lock()
+	defer recentEmojiMutex.Unlock()
+
+	if recentEmojis != nil {
+		return append([]string(nil), recentEmojis...)
+	}
+
+	recentEmojis = []string{}
+	path, err := getRecentEmojiFilePath()
+	if err != nil {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	for _, emoji := range strings.Split(string(content), "\n") {
+		if emoji = strings.TrimSpace(emoji); emoji != "" && len(recentEmojis) < maxRecentEmojis {
+			recentEmojis = append(recentEmojis, emoji)
+		}
+	}
+
+	return append([]string(nil), recentEmojis...)
+}
+
+// addRecentEmoji moves the emoji to the front of the recently used emojis and saves them.
+func addRecentEmoji(emoji string) error {
+	recent := loadRecentEmojis()
+
+	recentEmojiMutex.Lock()
+	defer recentEmojiMutex.Unlock()
+
+	recentEmojis = []string{emoji}
+	for _, e := range recent {
+		if e != emoji && len(recentEmojis) < maxRecentEmojis {
+			recentEmojis = append(recentEmojis, e)
+		}
+	}
+
+	path, err := getRecentEmojiFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(strings.Join(recentEmojis, "\n")+"\n"), 0o600)
+}
+
+// findShortcode returns the shortcode of the emoji, or an empty string if it is unknown.
+func findShortcode(emoji string) string {
+	for _, entry := range emojis {
+		if entry.emoji == emoji {
+			return entry.shortcode
+		}
+	}
+	return ""
+}
+
+// getPickerEntries returns the emojis listed by the picker for the search term. Without a term the
+// recently used emojis are listed first.
+func getPickerEntries(term string, recent []string) []emojiEntry {
+	if strings.Trim(term, ": ") != "" {
+		return searchEmojis(term)
+	}
+
+	var entries []emojiEntry
+	seen := make(map[string]bool)
+	for _, emoji := range recent {
+		entries = append(entries, emojiEntry{shortcode: findShortcode(emoji), emoji: emoji})
+		seen[emoji] = true
+	}
+	for _, entry := range emojis {
+		if !seen[entry.emoji] {
+			seen[entry.emoji] = true
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// showEmojiPicker opens an overlay to search and pick an emoji. Typing filters the emojis, Up and
+// Down select one, Enter picks it and Escape cancels. onDone is called with the picked emoji or
+// an empty string if the picker was cancelled.
+func (app *app) showEmojiPicker(onDone func(emoji string)) {
+	var entries []emojiEntry
+
+	list := tview.NewList().ShowSecondaryText(false)
+	search := tview.NewInputField().SetLabel("Search: ")
+
+	fill := func(term string) {
+		list.Clear()
+		entries = getPickerEntries(term, loadRecentEmojis())
+		for _, entry := range entries {
+			list.AddItem(formatEmojiEntry(entry), "", 0, nil)
+		}
+	}
+	fill("")
+
+	done := func(emoji string) {
+		pages.RemovePage(emojiPickerPageName)
+		if emoji != "" {
+			if err := addRecentEmoji(emoji); err != nil {
+				showInputHint("recent emojis could not be saved: " + err.Error())
+			}
+		}
+		onDone(emoji)
+	}
+
+	search.SetChangedFunc(fill)
+	search.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+			list.InputHandler()(event, func(tview.Primitive) {})
+			return nil
+		case tcell.KeyEnter:
+			if index := list.GetCurrentItem(); index >= 0 && index < len(entries) {
+				done(entries[index].emoji)
+			}
+			return nil
+		case tcell.KeyEscape:
+			done("")
+			return nil
+		}
+		return event
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(search, 1, 0, true).
+		AddItem(list, 0, 1, false)
+	layout.SetBorder(true).SetTitle(" emoji - Enter picks, Escape cancels ")
+
+	app.showOverlay(emojiPickerPageName, layout)
+	app.ui.SetFocus(search)
+}
@@ -0,0 +1,87 @@
+// main package
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandShortcodes(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "no shortcode", text: "hello", want: "hello"},
+		{name: "shortcode", text: "nice :+1: :tada:", want: "nice 👍 🎉"},
+		{name: "unknown shortcode", text: "at 12:30: :nope:", want: "at 12:30: :nope:"},
+		{name: "command argument", text: "/r 042 :heart:", want: "/r 042 ❤️"},
+		{name: "inline code", text: "`:+1:` is :+1:", want: "`:+1:` is 👍"},
+		{name: "code block", text: "```\n:+1:\n```\n:+1:", want: "```\n:+1:\n```\n👍"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, expandShortcodes(tt.text))
+		})
+	}
+}
+
+func TestCompleteShortcode(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   string
+		wantOk bool
+	}{
+		{name: "exact", text: "great :+1", want: "great 👍 ", wantOk: true},
+		{name: "unique prefix", text: ":rock", want: "🚀 ", wantOk: true},
+		{name: "ambiguous prefix", text: ":s", want: ":s", wantOk: false},
+		{name: "no shortcode", text: "hello", want: "hello", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := completeShortcode(tt.text)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSearchEmojis(t *testing.T) {
+	found := searchEmojis(":heart")
+	assert.Equal(t, "heart", found[0].shortcode)
+	for _, entry := range found {
+		assert.Contains(t, entry.shortcode, "heart")
+	}
+	assert.Empty(t, searchEmojis("does-not-exist"))
+}
+
+func TestFormatEmojiEntry(t *testing.T) {
+	assert.Equal(t, "👍 :+1:", formatEmojiEntry(emojiEntry{shortcode: "+1", emoji: "👍"}))
+	assert.Equal(t, "❤️ :heart:", formatEmojiEntry(emojiEntry{shortcode: "heart", emoji: "❤️"}))
+	assert.Equal(t, 2, emojiWidth("❤️"))
+}
+
+func TestRecentEmojis(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	recentEmojis = nil
+	t.Cleanup(func() { recentEmojis = nil })
+
+	assert.Empty(t, loadRecentEmojis())
+	assert.NoError(t, addRecentEmoji("👍"))
+	assert.NoError(t, addRecentEmoji("🎉"))
+	assert.NoError(t, addRecentEmoji("👍"))
+	assert.Equal(t, []string{"👍", "🎉"}, loadRecentEmojis())
+
+	// the recent emojis are read from disk
+	recentEmojis = nil
+	assert.Equal(t, []string{"👍", "🎉"}, loadRecentEmojis())
+
+	entries := getPickerEntries("", loadRecentEmojis())
+	assert.Equal(t, emojiEntry{shortcode: "+1", emoji: "👍"}, entries[0])
+	assert.Equal(t, emojiEntry{shortcode: "tada", emoji: "🎉"}, entries[1])
+	assert.Equal(t, "-1", entries[2].shortcode)
+}
@@ -2,7 +2,9 @@
 package main
 
 import (
-	"log"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -12,10 +14,6 @@ import (
 	"github.com/google/uuid"
 )
 
-func init() {
-	setClientID()
-}
-
 var (
 	mutex               sync.Mutex
 	clientUsernameCache = make(map[string]string)
@@ -29,7 +27,23 @@ var (
 		IP:       os.Getenv("LOCALCHAT_IP"),
 		Port:     os.Getenv("LOCALCHAT_PORT"),
 		Os:       runtime.GOOS,
-		ID:       setClientID(),
+
+		Notifier:        os.Getenv("LOCALCHAT_NOTIFIER"),
+		NotifierCommand: os.Getenv("LOCALCHAT_NOTIFIER_COMMAND"),
+
+		Hyperlinks:    os.Getenv("LOCALCHAT_HYPERLINKS"),
+		LinkAllowlist: os.Getenv("LOCALCHAT_LINK_ALLOWLIST"),
+
+		Theme: os.Getenv("LOCALCHAT_THEME"),
+		Plain: os.Getenv("LOCALCHAT_PLAIN"),
+
+		Keymap: os.Getenv("LOCALCHAT_KEYMAP"),
+
+		TimeFormat: os.Getenv("LOCALCHAT_TIME_FORMAT"),
+
+		ReadReceipts: os.Getenv("LOCALCHAT_READ_RECEIPTS"),
+
+		LogLevel: os.Getenv("LOCALCHAT_LOG_LEVEL"),
 	}
 )
 
@@ -50,6 +64,23 @@ type envVarsStruct struct {
 	Port     string `json:"port"`
 	Os       string `json:"os"`
 	ID       string `json:"id"`
+
+	Notifier        string `json:"notifier"`
+	NotifierCommand string `json:"notifierCommand"`
+
+	Hyperlinks    string `json:"hyperlinks"`
+	LinkAllowlist string `json:"linkAllowlist"`
+
+	Theme string `json:"theme"`
+	Plain string `json:"plain"`
+
+	Keymap string `json:"keymap"`
+
+	TimeFormat string `json:"timeFormat"`
+
+	ReadReceipts string `json:"readReceipts"`
+
+	LogLevel string `json:"logLevel"`
 }
 
 func addTypingClient(clientID string) {
@@ -131,6 +162,76 @@ func getEnvPort() string {
 	return envVars.Port
 }
 
+// getEnvNotifier returns the name of the configured notifier, beeep by default
+func getEnvNotifier() string {
+	if envVars.Notifier == "" {
+		return beeepNotifierName
+	}
+	return envVars.Notifier
+}
+
+func getEnvNotifierCommand() string {
+	return envVars.NotifierCommand
+}
+
+// getEnvHyperlinks reports whether links are rendered as OSC 8 hyperlinks, disabled with "off"
+func getEnvHyperlinks() bool {
+	switch strings.ToLower(envVars.Hyperlinks) {
+	case "off", "false", "0", "no":
+		return false
+	}
+	return true
+}
+
+// getEnvLinkAllowlist returns the hosts links may be opened for, an empty list allows all hosts
+func getEnvLinkAllowlist() []string {
+	var hosts []string
+	for _, host := range strings.Split(envVars.LinkAllowlist, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// getEnvTheme returns the name of the configured theme, a built-in one or a file in ~/.localchat/themes
+func getEnvTheme() string {
+	return envVars.Theme
+}
+
+// getEnvPlain reports whether the screen reader friendly plain mode is enabled with "on"
+func getEnvPlain() bool {
+	switch strings.ToLower(envVars.Plain) {
+	case "on", "true", "1", "yes":
+		return true
+	}
+	return false
+}
+
+// getEnvKeymap returns the name of the configured keymap, a preset or a file in ~/.localchat/keymaps
+func getEnvKeymap() string {
+	return envVars.Keymap
+}
+
+// getEnvTimeFormat returns the format of message times: 24h (default), 12h or relative
+func getEnvTimeFormat() string {
+	return normalizeTimeFormat(envVars.TimeFormat)
+}
+
+// getEnvReadReceipts reports whether read receipts are sent and shown, enabled with "on"
+func getEnvReadReceipts() bool {
+	switch strings.ToLower(envVars.ReadReceipts) {
+	case "on", "true", "1", "yes":
+		return true
+	}
+	return false
+}
+
+// getEnvLogLevel returns the configured log level: debug, info (default), warn or error
+func getEnvLogLevel() string {
+	return envVars.LogLevel
+}
+
 func getThisClient() client {
 	return thisClient
 }
@@ -153,6 +254,36 @@ func appendMessageToCache(message messagePayload) (index int) {
 	return index
 }
 
+// replaceMessageInCache replaces the cached message with the same MessageDbID, e.g. after it was
+// edited or deleted. It returns false if the message is not cached.
+func replaceMessageInCache(message messagePayload) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for index, cached := range messageCache {
+		if cached.MessageType.MessageDbID == message.MessageType.MessageDbID {
+			messageCache[index] = message
+			return true
+		}
+	}
+
+	return false
+}
+
+// getMessageByDbIDFromCache returns the cached message with the given MessageDbID.
+func getMessageByDbIDFromCache(messageDbID string) (messagePayload, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, cached := range messageCache {
+		if cached.MessageType.MessageDbID == messageDbID {
+			return cached, true
+		}
+	}
+
+	return messagePayload{}, false
+}
+
 func getMessageFromCache(index int) messagePayload {
 	mutex.Lock()
 	defer mutex.Unlock()
@@ -160,6 +291,19 @@ func getMessageFromCache(index int) messagePayload {
 	return messageCache[index]
 }
 
+// getMessagesFromCache returns a copy of all cached messages, ordered by their index.
+func getMessagesFromCache() []messagePayload {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	messages := make([]messagePayload, len(messageCache))
+	for index, message := range messageCache {
+		messages[index] = message
+	}
+
+	return messages
+}
+
 func addUsernameToCache(clientID string, username string) {
 	clientUsernameCache[clientID] = username
 }
@@ -185,6 +329,56 @@ func setClientList(newClientList *clientListStruct) {
 	cacheThisClient()
 }
 
+// getClientListFilePath returns the path the last received client list is saved to
+func getClientListFilePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".localchat", "clients", "clients.json"), nil
+}
+
+// saveClientList saves the client list so that usernames can be resolved without a connection
+func saveClientList(clientList *clientListStruct) error {
+	clientListFilePath, err := getClientListFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(clientListFilePath), 0o700); err != nil {
+		return err
+	}
+
+	clientListBytes, err := json.Marshal(clientList)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(clientListFilePath, clientListBytes, 0o600)
+}
+
+// loadClientList loads the last saved client list
+func loadClientList() error {
+	clientListFilePath, err := getClientListFilePath()
+	if err != nil {
+		return err
+	}
+
+	clientListBytes, err := os.ReadFile(clientListFilePath)
+	if err != nil {
+		return err
+	}
+
+	var savedClientList clientListStruct
+	if err := json.Unmarshal(clientListBytes, &savedClientList); err != nil {
+		return err
+	}
+
+	setClientList(&savedClientList)
+	return nil
+}
+
 func cacheThisClient() {
 	for _, v := range clientList.Clients {
 		if v.ClientDbID == envVars.ID {
@@ -194,25 +388,40 @@ func cacheThisClient() {
 	}
 }
 
+// getClientProfileImage returns the profile image of the client with the given client id
+func getClientProfileImage(clientID string) string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, v := range clientList.Clients {
+		if v.ClientDbID == clientID {
+			return v.ClientProfileImage
+		}
+	}
+	return ""
+}
+
 func resetColorCache() {
 	clientColorCache = make(map[string]string)
 }
 
-func setClientID() string {
+// loadClientID returns the id of this client, which is kept in ~/.localchat/id/id.txt. A new id is
+// generated on the first start. With DEV=true every start gets a random id.
+func loadClientID() (string, error) {
 	// if dev=true environment variable is set, use a random id
 	if os.Getenv("DEV") == "true" {
-		return uuid.New().String()
+		return uuid.New().String(), nil
 	}
 
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		log.Fatalf("error retrieving home path: %v", err)
+		return "", fmt.Errorf("retrieving home path failed: %w", err)
 	}
 
 	idFilePath := filepath.Join(homeDir, ".localchat", "id", "id.txt")
 
 	if err := os.MkdirAll(filepath.Dir(idFilePath), 0o700); err != nil {
-		log.Fatalf("error creating folder: %v", err)
+		return "", fmt.Errorf("creating id folder failed: %w", err)
 	}
 
 	if _, err := os.Stat(idFilePath); os.IsNotExist(err) {
@@ -221,25 +430,36 @@ func setClientID() string {
 
 		// save id in file
 		if err := os.WriteFile(idFilePath, []byte(newID), 0o600); err != nil {
-			log.Fatalf("error saving the id: %v", err)
+			return "", fmt.Errorf("saving the id failed: %w", err)
 		}
 
-		log.Printf("new id generated and saved: %s", newID)
-		return newID
+		slog.Info("new id generated and saved", "id", newID)
+		return newID, nil
 	}
 
 	// id exists -> read id from file
 	id, err := os.ReadFile(idFilePath)
 	if err != nil {
-		log.Fatalf("error reading id: %v", err)
+		return "", fmt.Errorf("reading the id failed: %w", err)
 	}
 
-	log.Printf("id was read from file: %s", string(id))
-	return string(id)
+	slog.Info("id was read from file", "id", string(id))
+	return string(id), nil
+}
+
+// setupClientID loads the id of this client into envVars. It is called by the GUI and by the
+// headless commands before they authenticate.
+func setupClientID() error {
+	id, err := loadClientID()
+	if err != nil {
+		return fmt.Errorf("failed to load client id: %w", err)
+	}
+	envVars.ID = id
+	return nil
 }
 
 // getClientColor returns the color of the client with the given client id
-// return yellow if the client did not choose a color yet
+// return the default user color of the theme if the client did not choose a color yet
 func getClientColor(clientID string) string {
 	mutex.Lock()
 	defer mutex.Unlock()
@@ -259,8 +479,8 @@ func getClientColor(clientID string) string {
 		}
 	}
 
-	// if the color is not in the cache and not in the client list, return nil
-	return "yellow"
+	// if the color is not in the cache and not in the client list, return the default
+	return currentTheme.DefaultUser
 }
 
 func addClientColorToCache(id string, color string) {
@@ -0,0 +1,505 @@
+// main package
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	exportFormatMarkdown = "md"
+	exportFormatHTML     = "html"
+	exportFormatJSONL    = "jsonl"
+	exportFormatText     = "txt"
+)
+
+// exportOptions selects the format, destination and messages of an export.
+// From and to are inclusive local dates in the MessageDate format (2006-01-02).
+type exportOptions struct {
+	format   string
+	output   string
+	from     string
+	to       string
+	users    []string
+	archived bool
+}
+
+type exportedQuote struct {
+	Username string `json:"username"`
+	Text     string `json:"text"`
+	Date     string `json:"date"`
+	Time     string `json:"time"`
+}
+
+type exportedReaction struct {
+	Username string `json:"username"`
+	Reaction string `json:"reaction"`
+}
+
+type exportedMessage struct {
+	Quote     *exportedQuote     `json:"quote,omitempty"`
+	ID        string             `json:"id"`
+	Timestamp string             `json:"timestamp,omitempty"`
+	ReplyTo   string             `json:"replyTo,omitempty"`
+	Date      string             `json:"date"`
+	Time      string             `json:"time"`
+	Username  string             `json:"username"`
+	Color     string             `json:"color"`
+	Text      string             `json:"text"`
+	Reactions []exportedReaction `json:"reactions,omitempty"`
+	Edited    bool               `json:"edited"`
+	Deleted   bool               `json:"deleted"`
+}
+
+// usersFlag collects repeated --user flags.
+type usersFlag []string
+
+func (uf *usersFlag) String() string {
+	return strings.Join(*uf, ",")
+}
+
+func (uf *usersFlag) Set(value string) error {
+	*uf = append(*uf, value)
+	return nil
+}
+
+// parseExportArgs parses the arguments shared by the /export command and the export subcommand.
+func parseExportArgs(args []string, output io.Writer) (exportOptions, error) {
+	var options exportOptions
+	var users usersFlag
+
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&options.format, "format", exportFormatMarkdown, "export format: md, html, jsonl or txt")
+	flags.StringVar(&options.output, "o", "", "output file (default localchat-export-<date>.<format>)")
+	flags.StringVar(&options.from, "from", "", "only export messages from this date on (2006-01-02)")
+	flags.StringVar(&options.to, "to", "", "only export messages up to this date (2006-01-02)")
+	flags.Var(&users, "user", "only export messages of this user, can be repeated")
+	flags.BoolVar(&options.archived, "a", false, "export the local archive instead of the loaded messages")
+
+	if err := flags.Parse(args); err != nil {
+		return options, err
+	}
+	options.users = users
+
+	switch options.format {
+	case exportFormatMarkdown, exportFormatHTML, exportFormatJSONL, exportFormatText:
+	default:
+		return options, fmt.Errorf("unknown export format %q", options.format)
+	}
+
+	for _, date := range []string{options.from, options.to} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return options, fmt.Errorf("invalid date %q, expected 2006-01-02", date)
+		}
+	}
+
+	if options.output == "" {
+		options.output = fmt.Sprintf("localchat-export-%s.%s", time.Now().Format("2006-01-02"), options.format)
+	}
+
+	return options, nil
+}
+
+// filterMessages returns the messages within the date range of the options, sent by one of its users.
+func filterMessages(messages []messagePayload, options exportOptions) []messagePayload {
+	var filtered []messagePayload
+
+	for _, payload := range messages {
+		date := getMessageDay(payload)
+		if options.from != "" && date < options.from {
+			continue
+		}
+		if options.to != "" && date > options.to {
+			continue
+		}
+		if len(options.users) > 0 {
+			if _, ok := lookupUsername(getUsernameForID(payload.ClientType.ClientDbID), options.users); !ok {
+				continue
+			}
+		}
+		filtered = append(filtered, payload)
+	}
+
+	return filtered
+}
+
+// newExportedMessage decodes the payload and resolves all usernames.
+func newExportedMessage(payload messagePayload) exportedMessage {
+	decodedString, err := decodeBase64ToString(payload.MessageType.MessageContext)
+	if err != nil {
+		slog.Warn("decoding base64 to string failed", "err", err)
+	}
+
+	exported := exportedMessage{
+		ID:        payload.MessageType.MessageDbID,
+		Timestamp: payload.MessageType.Timestamp,
+		ReplyTo:   payload.MessageType.ParentMessageDbID,
+		Date:      payload.MessageType.MessageDate,
+		Time:      payload.MessageType.MessageTime,
+		Username:  getUsernameForID(payload.ClientType.ClientDbID),
+		Color:     getClientColor(payload.ClientType.ClientDbID),
+		Text:      decodedString,
+		Edited:    payload.MessageType.Edited,
+		Deleted:   payload.MessageType.Deleted,
+	}
+	// date and time are exported in the local time zone
+	if t, ok := getMessageTimestamp(payload); ok {
+		exported.Date, exported.Time = t.Local().Format(dateLayout), t.Local().Format("15:04")
+	}
+
+	if payload.QuoteType != nil && payload.QuoteType.QuoteClientID != "" {
+		quote, err := decodeBase64ToString(payload.QuoteType.QuoteMessageContext)
+		if err != nil {
+			slog.Warn("decoding base64 to string failed", "err", err)
+		}
+		exported.Quote = &exportedQuote{
+			Username: getUsernameForID(payload.QuoteType.QuoteClientID),
+			Text:     quote,
+			Date:     payload.QuoteType.QuoteDate,
+			Time:     payload.QuoteType.QuoteTime,
+		}
+	}
+
+	if payload.ReactionType != nil {
+		for _, reaction := range effectiveReactions(*payload.ReactionType) {
+			exported.Reactions = append(exported.Reactions, exportedReaction{
+				Username: getUsernameForID(reaction.ReactionClientID),
+				Reaction: reaction.ReactionContext,
+			})
+		}
+	}
+
+	return exported
+}
+
+// exportFlags returns the edited and deleted flags of the message, e.g. " (edited)".
+func exportFlags(message exportedMessage) string {
+	var flags string
+	if message.Edited {
+		flags += " (edited)"
+	}
+	if message.Deleted {
+		flags += " (deleted)"
+	}
+	return flags
+}
+
+// exportReactions formats the reactions as "👍 Alice, 🎉 Bob".
+func exportReactions(message exportedMessage) string {
+	reactions := make([]string, 0, len(message.Reactions))
+	for _, reaction := range message.Reactions {
+		reactions = append(reactions, reaction.Reaction+" "+reaction.Username)
+	}
+	return strings.Join(reactions, ", ")
+}
+
+// exportMessages writes the messages to w in the given format.
+func exportMessages(w io.Writer, messages []messagePayload, format string) error {
+	exported := make([]exportedMessage, 0, len(messages))
+	for _, payload := range messages {
+		exported = append(exported, newExportedMessage(payload))
+	}
+
+	switch format {
+	case exportFormatMarkdown:
+		return exportMarkdown(w, exported)
+	case exportFormatHTML:
+		return exportHTML(w, exported)
+	case exportFormatJSONL:
+		return exportJSONL(w, exported)
+	case exportFormatText:
+		return exportText(w, exported)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// markdownEscaper escapes the characters which have a meaning in Markdown wherever they appear.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`, "~", `\~`,
+)
+
+// markdownListMarkerRegex matches line starts which would begin a list or a heading underline.
+var markdownListMarkerRegex = regexp.MustCompile(`^(\s*)([-+=]|\d+[.)])`)
+
+// escapeMarkdown escapes text so it is shown as is in a Markdown document, e.g. "*wip*" becomes
+// "\*wip\*". Line starts like "- " or "1. " are escaped as well.
+func escapeMarkdown(text string) string {
+	lines := strings.Split(markdownEscaper.Replace(text), "\n")
+	for i, line := range lines {
+		if loc := markdownListMarkerRegex.FindStringSubmatchIndex(line); loc != nil {
+			// the escape goes in front of the last character of the marker, e.g. "\-" or "1\."
+			marker := loc[5] - 1
+			lines[i] = line[:marker] + `\` + line[marker:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatMarkdownText escapes the text and keeps its line breaks. Lines after the first one are
+// prefixed with indent, so they stay in the list item or quote they belong to.
+func formatMarkdownText(text string, indent string) string {
+	lines := strings.Split(escapeMarkdown(text), "\n")
+
+	var builder strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			// a backslash at the end of a line is a hard line break, blank lines separate paragraphs
+			if line != "" && lines[i-1] != "" {
+				builder.WriteString(`\`)
+			}
+			builder.WriteString("\n")
+			if line == "" {
+				builder.WriteString(strings.TrimRight(indent, " "))
+			} else {
+				builder.WriteString(indent)
+			}
+		}
+		builder.WriteString(line)
+	}
+	return builder.String()
+}
+
+// exportMarkdown writes every message as a list item. The quote and the reactions are part of the
+// item, their lines are indented below it.
+func exportMarkdown(w io.Writer, messages []exportedMessage) error {
+	buffered := bufio.NewWriter(w)
+
+	fmt.Fprintln(buffered, "# localchat export")
+	for _, message := range messages {
+		fmt.Fprintln(buffered)
+		if message.Quote != nil {
+			fmt.Fprintf(buffered, "- > **%s** (%s %s): %s\n\n  ", escapeMarkdown(message.Quote.Username),
+				message.Quote.Date, message.Quote.Time, formatMarkdownText(message.Quote.Text, "  > "))
+		} else {
+			fmt.Fprint(buffered, "- ")
+		}
+		fmt.Fprintf(buffered, "**%s** (%s %s)%s: %s\n", escapeMarkdown(message.Username), message.Date,
+			message.Time, exportFlags(message), formatMarkdownText(message.Text, "  "))
+		if len(message.Reactions) > 0 {
+			fmt.Fprintf(buffered, "\n  _%s_\n", escapeMarkdown(exportReactions(message)))
+		}
+	}
+
+	return buffered.Flush()
+}
+
+var exportHTMLTemplate = template.Must(template.New("export").Funcs(template.FuncMap{
+	"flags":     exportFlags,
+	"reactions": exportReactions,
+	"color": func(color string) template.CSS {
+		if checkIfHexColor(color) {
+			return template.CSS("color: " + color)
+		}
+		return ""
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>localchat export</title>
+<style>
+body { font-family: sans-serif; background: #1e1e1e; color: #ddd; }
+.message { margin: 0.5em 0; }
+.meta { color: #888; }
+.user { font-weight: bold; }
+.text { white-space: pre-wrap; }
+.quote { border-left: 3px solid #997275; padding-left: 0.5em; color: #aaa; }
+.reactions { color: #8B8000; }
+</style>
+</head>
+<body>
+<h1>localchat export</h1>
+{{range .}}<div class="message">
+{{with .Quote}}<div class="quote"><span class="user">{{.Username}}</span> <span class="meta">{{.Date}} {{.Time}}</span>: <span class="text">{{.Text}}</span></div>
+{{end}}<span class="meta">{{.Date}} {{.Time}}</span> <span class="user" style="{{color .Color}}">{{.Username}}</span>{{flags .}}: <span class="text">{{.Text}}</span>
+{{if .Reactions}}<div class="reactions">{{reactions .}}</div>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+func exportHTML(w io.Writer, messages []exportedMessage) error {
+	return exportHTMLTemplate.Execute(w, messages)
+}
+
+func exportJSONL(w io.Writer, messages []exportedMessage) error {
+	encoder := json.NewEncoder(w)
+	for _, message := range messages {
+		if err := encoder.Encode(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportText(w io.Writer, messages []exportedMessage) error {
+	buffered := bufio.NewWriter(w)
+
+	for _, message := range messages {
+		if message.Quote != nil {
+			fmt.Fprintf(buffered, "  > %s: %s\n", message.Quote.Username, message.Quote.Text)
+		}
+		fmt.Fprintf(buffered, "[%s %s] %s%s: %s\n", message.Date, message.Time, message.Username,
+			exportFlags(message), message.Text)
+		if len(message.Reactions) > 0 {
+			fmt.Fprintf(buffered, "  reactions: %s\n", exportReactions(message))
+		}
+	}
+
+	return buffered.Flush()
+}
+
+// exportToFile filters the messages and writes them to the output file of the options.
+// It returns the number of exported messages.
+func exportToFile(messages []messagePayload, options exportOptions) (int, error) {
+	filtered := filterMessages(messages, options)
+
+	if dir := filepath.Dir(options.output); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return 0, err
+		}
+	}
+
+	file, err := os.Create(options.output)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := exportMessages(file, filtered, options.format); err != nil {
+		file.Close()
+		return 0, err
+	}
+
+	return len(filtered), file.Close()
+}
+
+// exportCommand exports the loaded messages, or the local archive with -a, from within the chat.
+// Values containing spaces are quoted, e.g. /export -o "my export.md".
+func (app *app) exportCommand(argument string) {
+	args, err := splitQuotedArgs(argument)
+	if err != nil {
+		writeSystemLine(fmt.Sprintf("export failed: %v", err))
+		return
+	}
+
+	var usage strings.Builder
+	options, err := parseExportArgs(args, &usage)
+	if err != nil {
+		// invalid flags are reported together with the usage, other errors on their own
+		if usage.Len() == 0 {
+			writeSystemLine(fmt.Sprintf("export failed: %v", err))
+		}
+		for _, line := range strings.Split(strings.TrimRight(usage.String(), "\n"), "\n") {
+			if line != "" {
+				writeSystemLine(line)
+			}
+		}
+		return
+	}
+
+	messages := getMessagesFromCache()
+	if options.archived {
+		messages = app.archive.getMessages()
+	}
+
+	count, err := exportToFile(messages, options)
+	if err != nil {
+		writeSystemLine(fmt.Sprintf("export failed: %v", err))
+		return
+	}
+
+	writeSystemLine(fmt.Sprintf("exported %d messages to %s", count, options.output))
+}
+
+// splitQuotedArgs splits the argument at spaces like a shell. Single or double quotes keep spaces
+// within a value, e.g. -o "my export.md". Backslashes are kept as they are, for Windows paths.
+func splitQuotedArgs(argument string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range argument {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("missing closing quote %c", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// runExportCommand implements "localterm export", which exports the local archive without
+// connecting to the server. It returns the exit code.
+func runExportCommand(args []string) int {
+	options, err := parseExportArgs(args, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if err := loadClientList(); err != nil {
+		fmt.Fprintln(os.Stderr, "usernames cannot be resolved:", err)
+	}
+
+	archivePath, err := getArchivePath()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	archive, err := openArchive(archivePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer archive.close()
+
+	count, err := exportToFile(archive.getMessages(), options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("exported %d messages to %s\n", count, options.output)
+	return 0
+}
@@ -0,0 +1,187 @@
+// main package
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestExportMessages(t *testing.T) []messagePayload {
+	t.Helper()
+
+	setTestClientList(t,
+		client{ClientDbID: "1", ClientUsername: "Alice", ClientColor: "#ff0000"},
+		client{ClientDbID: "2", ClientUsername: "Bob"})
+
+	first := newTestMessagePayload("1", "build is red")
+	first.MessageType.MessageDate = "2024-05-01"
+	first.MessageType.MessageTime = "09:00"
+
+	second := newTestMessagePayload("2", "<fixed>")
+	second.MessageType.MessageDate = "2024-05-02"
+	second.MessageType.MessageTime = "10:30"
+	second.MessageType.Edited = true
+	second.QuoteType = &quoteType{
+		QuoteClientID:       "1",
+		QuoteMessageContext: base64.StdEncoding.EncodeToString([]byte("build is red")),
+		QuoteDate:           "2024-05-01",
+		QuoteTime:           "09:00",
+	}
+	second.ReactionType = &[]reactionType{{ReactionContext: "🎉", ReactionClientID: "1"}}
+
+	return []messagePayload{first, second}
+}
+
+func TestParseExportArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "defaults", args: nil},
+		{name: "all flags", args: []string{"-format", "html", "-o", "out.html", "-from", "2024-01-01", "-to", "2024-12-31", "-user", "Alice", "-user", "Bob", "-a"}},
+		{name: "unknown format", args: []string{"-format", "pdf"}, wantErr: true},
+		{name: "invalid date", args: []string{"-from", "yesterday"}, wantErr: true},
+		{name: "unknown flag", args: []string{"-x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := parseExportArgs(tt.args, io.Discard)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotEmpty(t, options.output)
+		})
+	}
+}
+
+func TestSplitQuotedArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		argument string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "empty", argument: "  ", want: nil},
+		{name: "plain", argument: "-format txt  -a", want: []string{"-format", "txt", "-a"}},
+		{name: "double quotes", argument: `-o "my export.md" -a`, want: []string{"-o", "my export.md", "-a"}},
+		{name: "single quotes", argument: `-user 'Mary Ann'`, want: []string{"-user", "Mary Ann"}},
+		{name: "quoted part", argument: `-o exports/"team chat".md`, want: []string{"-o", "exports/team chat.md"}},
+		{name: "empty quotes", argument: `-user ""`, want: []string{"-user", ""}},
+		{name: "backslashes are kept", argument: `-o C:\exports\chat.md`, want: []string{"-o", `C:\exports\chat.md`}},
+		{name: "unterminated quote", argument: `-o "my export.md`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitQuotedArgs(tt.argument)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFilterMessages(t *testing.T) {
+	messages := newTestExportMessages(t)
+
+	tests := []struct {
+		name    string
+		options exportOptions
+		want    int
+	}{
+		{name: "no filter", options: exportOptions{}, want: 2},
+		{name: "from", options: exportOptions{from: "2024-05-02"}, want: 1},
+		{name: "to", options: exportOptions{to: "2024-05-01"}, want: 1},
+		{name: "user", options: exportOptions{users: []string{"alice"}}, want: 1},
+		{name: "unknown user", options: exportOptions{users: []string{"carl"}}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, filterMessages(messages, tt.options), tt.want)
+		})
+	}
+}
+
+func TestExportMessages(t *testing.T) {
+	messages := newTestExportMessages(t)
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{
+			format: exportFormatMarkdown,
+			want:   []string{"**Alice** (2024-05-01 09:00): build is red", "> **Alice** (2024-05-01 09:00): build is red", "  **Bob** (2024-05-02 10:30) (edited): \\<fixed\\>", "  _🎉 Alice_"},
+		},
+		{
+			format: exportFormatHTML,
+			want:   []string{"<!DOCTYPE html>", `style="color: #ff0000"`, "&lt;fixed&gt;", "(edited)"},
+		},
+		{
+			format: exportFormatJSONL,
+			want:   []string{`"username":"Alice"`, `"quote":{"username":"Alice"`, `"reactions":[{"username":"Alice","reaction":"🎉"}]`, `"edited":true`},
+		},
+		{
+			format: exportFormatText,
+			want:   []string{"[2024-05-01 09:00] Alice: build is red", "  > Alice: build is red", "[2024-05-02 10:30] Bob (edited): <fixed>", "  reactions: 🎉 Alice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.NoError(t, exportMessages(&out, messages, tt.format))
+			for _, want := range tt.want {
+				assert.Contains(t, out.String(), want)
+			}
+		})
+	}
+
+	assert.Error(t, exportMessages(io.Discard, messages, "pdf"))
+}
+
+func TestFormatMarkdownText(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		indent string
+		want   string
+	}{
+		{name: "plain", text: "build is red", indent: "  ", want: "build is red"},
+		{name: "special characters", text: "*wip* on feature_x #42 <b>", indent: "  ", want: `\*wip\* on feature\_x \#42 \<b\>`},
+		{name: "line starts", text: "todo:\n- one\n> two\n1. three", indent: "  ", want: "todo:\\\n  \\- one\\\n  \\> two\\\n  1\\. three"},
+		{name: "blank line", text: "one\n\ntwo", indent: "  > ", want: "one\n  >\n  > two"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, formatMarkdownText(tt.text, tt.indent))
+		})
+	}
+}
+
+func TestExportMessages_JSONLLines(t *testing.T) {
+	messages := newTestExportMessages(t)
+
+	var out bytes.Buffer
+	assert.NoError(t, exportMessages(&out, messages, exportFormatJSONL))
+	assert.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 2)
+}
+
+func TestRunExportCommandHelp(t *testing.T) {
+	assert.Equal(t, 0, runExportCommand([]string{"-h"}))
+	assert.Equal(t, 2, runExportCommand([]string{"-format", "pdf"}))
+}
@@ -2,9 +2,12 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"log/slog"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -13,7 +16,6 @@ import (
 	"github.com/gdamore/tcell/v2"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-
 	"github.com/rivo/tview"
 )
 
@@ -30,18 +32,37 @@ var (
 	chatView   *tview.TextView
 	flex       tview.Flex
 	typingView *tview.TextView
+	statusView *tview.TextView
 	inputField *tview.InputField
+	pages      *tview.Pages
 )
 
+const mainPageName = "main"
+
 func newApp(ui *tview.Application, conn *websocket.Conn) (*app, error) {
 	// initial request to websocket after handshake
 	// asks for all RegisteredUsers in a clientList
 	err := authenticateClientAtSocket(conn)
 
+	notifier, notifierErr := newNotifier(getEnvNotifier(), getEnvNotifierCommand())
+	if notifierErr != nil {
+		return nil, notifierErr
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	// notifications are sent from timers, the escape sequences are written by the UI goroutine
+	if tn, ok := notifier.(*terminalNotifier); ok {
+		tn.out = newUIWriter(ctx, ui, tn.out)
+	}
+
 	return &app{
-		ui:       ui,
-		notifier: &beeepNotifier{},
-		conn:     conn,
+		ui:            ui,
+		notifier:      notifier,
+		notifications: newNotificationBatcher(notifier),
+		conn:          conn,
+		ctx:           ctx,
+		cancel:        cancel,
 	}, err
 }
 
@@ -51,40 +72,115 @@ func changeTextLabelText(text string) {
 
 func createTypingView(app *app) *tview.TextView {
 	textView := tview.NewTextView().
+		SetDynamicColors(true).
 		SetChangedFunc(func() {
 			app.ui.Draw()
 		})
-	textView.SetTextColor(tcell.ColorGray)
+	textView.SetTextColor(themeColor(currentTheme.Muted))
 
 	return textView
 }
 
 func (app *app) setTypingLabelText(text string) {
-	typingView.SetText(text)
-	app.ui.Draw()
+	typingView.SetText(tview.Escape(text))
+}
+
+// showInputHint shows a hint or error for the current input below the input field,
+// it is cleared as soon as the input changes
+func showInputHint(text string) {
+	typingView.SetText(colorTag(currentTheme.Error) + tview.Escape(text) + "[-]")
+}
+
+// clearInputHint removes the input hint, leaving the typing indicator
+func clearInputHint() {
+	if typingView == nil {
+		return
+	}
+	typingView.SetText(tview.Escape(generateTypingString()))
 }
 
 func createChatView(app *app) *tview.TextView {
 	textView := tview.NewTextView().
 		SetDynamicColors(true).
+		SetRegions(true).
 		SetScrollable(true).
 		SetChangedFunc(func() {
 			app.ui.Draw()
 		})
+	// message selection mode, see selection.go
+	textView.SetInputCapture(app.handleSelectionKey)
+	// scrolling to the end marks the messages as read, see markVisibleAsRead
+	textView.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		switch action {
+		case tview.MouseScrollUp:
+			chatFollowsEnd = false
+		case tview.MouseScrollDown:
+			row, _ := textView.GetScrollOffset()
+			chatFollowsEnd = chatFollowsEnd || row+1 >= chatEndRow
+		}
+		app.markVisibleAsRead()
+		return action, event
+	})
+
+	return textView
+}
+
+func createStatusView() *tview.TextView {
+	textView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignRight)
 
 	return textView
 }
 
 func addNewMessageToScrollPanel(index *int, payload *messagePayload) {
-	messageIndex := fmt.Sprintf("[gray][%03d][-]", *index)
+	// replies are shown in the thread pane, the main view shows the number of replies below the parent
+	messages := getMessagesFromCache()
+	if getThreadParentIndex(messages, *payload) >= 0 {
+		return
+	}
+	replies := formatReplyCount(countReplies(messages, payload.MessageType.MessageDbID))
+
+	writeDaySeparator(*payload)
+
+	if _, err := fmt.Fprintf(chatView, "[\"%s\"]%s%s[\"\"]\n", messageRegionID(*index),
+		formatMessage(*index, payload), replies); err != nil {
+		slog.Error("writing to chatView failed", "err", err)
+	}
+
+	scrollChatToEnd()
+}
+
+// formatMessage formats a message with its quote and reactions as shown in the chat view.
+func formatMessage(index int, payload *messagePayload) string {
+	messageIndex := fmt.Sprintf("%s[%03d][-]", colorTag(currentTheme.Muted), index)
+	clock := formatMessageClock(*payload)
+	username := getUsernameForID(payload.ClientType.ClientDbID)
 	decodedString, err := decodeBase64ToString(payload.MessageType.MessageContext)
 	if err != nil {
-		fmt.Println("Error decoding base64 to string:", err)
+		slog.Error("decoding base64 to string failed", "err", err)
 	}
 
-	payloadUsername := getUsernameForID(payload.ClientType.ClientDbID)
+	// the text is escaped and rendered as markdown, links and mentions are highlighted outside of code
+	usernames, ownUsername := getClientUsernames(), getThisClientUsername()
+	decodedString = renderMarkdown(decodedString, func(text string) string {
+		return linkifyURLs(text, func(text string) string {
+			return highlightMentions(text, usernames, ownUsername)
+		})
+	})
+	// matches of the current search are marked, see highlightSearchTerm
+	decodedString = highlightSearchTerm(decodedString, currentSearch.term)
+	// continuation lines of multi-line messages are indented below the first line of the text
+	decodedString = indentContinuationLines(decodedString, continuationIndent(fmt.Sprintf("[%03d] %s - %s: ", index, clock, username)))
+
+	if payload.MessageType.Deleted {
+		decodedString = "[" + currentTheme.Muted + "::i]message deleted[-::-]"
+	} else if payload.MessageType.Edited {
+		decodedString += " " + colorTag(currentTheme.Muted) + "(edited)[-]"
+	}
+	decodedString += formatDeliveryStatus(index, *payload)
 
-	usernameColor := fmt.Sprintf("[%s]", getClientColor(payload.ClientType.ClientDbID))
+	usernameColor := colorTag(getDisplayColor(payload.ClientType.ClientDbID))
 
 	var quote string
 	if payload.QuoteType != nil {
@@ -96,14 +192,100 @@ func addNewMessageToScrollPanel(index *int, payload *messagePayload) {
 		reactions = checkForReactions(*payload.ReactionType)
 	}
 
-	if _, err = fmt.Fprintf(chatView, "%s%s [-]%s - %s%s:[-] %s %s\n", quote, messageIndex,
-		payload.MessageType.MessageTime,
+	return fmt.Sprintf("%s%s [-]%s - %s%s:[-] %s %s",
+		quote, messageIndex,
+		clock,
 		usernameColor,
-		payloadUsername, decodedString, reactions); err != nil {
-		fmt.Println("Error writing to chatView:", err)
+		highlightSearchTerm(tview.Escape(username), currentSearch.term), decodedString, reactions)
+}
+
+// writeSystemLine prints a local-only line into the chat view, e.g. the result of a command
+func writeSystemLine(text string) {
+	if _, err := fmt.Fprintf(chatView, "%s%s*** %s[-]\n", margin, colorTag(currentTheme.Muted), tview.Escape(text)); err != nil {
+		slog.Error("writing to chatView failed", "err", err)
 	}
+	scrollChatToEnd()
+}
 
-	chatView.ScrollToEnd()
+// formatMessageSummary formats a message as a single line for overlays like the mentions view,
+// e.g. "2024-05-01 15:04 - Alice: hello"
+func formatMessageSummary(payload messagePayload, usernames []string, ownUsername string) string {
+	decodedString, err := decodeBase64ToString(payload.MessageType.MessageContext)
+	if err != nil {
+		slog.Error("decoding base64 to string failed", "err", err)
+	}
+
+	return fmt.Sprintf("%s %s - [%s]%s:[-] %s",
+		getMessageDay(payload),
+		formatMessageClock(payload),
+		getDisplayColor(payload.ClientType.ClientDbID),
+		tview.Escape(getUsernameForID(payload.ClientType.ClientDbID)),
+		highlightMentions(tview.Escape(strings.ReplaceAll(decodedString, "\n", " ")), usernames, ownUsername))
+}
+
+// redrawMessage renders the message with the given MessageDbID again in place, see redrawMessages.
+func (app *app) redrawMessage(messageDbID string) {
+	for index, payload := range getMessagesFromCache() {
+		if payload.MessageType.MessageDbID == messageDbID {
+			app.redrawMessages(index)
+			return
+		}
+	}
+}
+
+// redrawAllMessages renders all messages again in place, see redrawMessages.
+func (app *app) redrawAllMessages() {
+	indices := make([]int, len(getMessagesFromCache()))
+	for i := range indices {
+		indices[i] = i
+	}
+	app.redrawMessages(indices...)
+}
+
+// redrawMessages renders the messages with the given indices again in place, e.g. after their
+// delivery state changed, without rendering the whole chat view again. Replies are not shown in
+// the chat view, the open thread pane is rendered again for them.
+func (app *app) redrawMessages(indices ...int) {
+	messages := getMessagesFromCache()
+	contents := make(map[string]string, len(indices))
+	for _, index := range indices {
+		if index < 0 || index >= len(messages) || getThreadParentIndex(messages, messages[index]) >= 0 {
+			continue
+		}
+		contents[messageRegionID(index)] = formatMessage(index, &messages[index]) +
+			formatReplyCount(countReplies(messages, messages[index].MessageType.MessageDbID))
+	}
+
+	if len(contents) > 0 {
+		chatView.SetText(replaceRegions(chatView.GetText(false), contents))
+	}
+	renderThread()
+}
+
+// replaceRegions replaces the content of the regions in text, which is tagged like
+// ["msg-1"]content[""]. Regions missing in text are left out. Tags in the content of messages are
+// escaped, so the end of a region cannot be part of it.
+func replaceRegions(text string, contents map[string]string) string {
+	for regionID, content := range contents {
+		startTag := `["` + regionID + `"]`
+		start := strings.Index(text, startTag)
+		if start < 0 {
+			continue
+		}
+		start += len(startTag)
+
+		end := strings.Index(text[start:], `[""]`)
+		if end < 0 {
+			continue
+		}
+		text = text[:start] + content + text[start+end:]
+	}
+	return text
+}
+
+// messageRegionID returns the id of the chat view region containing the message with the given index
+func messageRegionID(index int) string {
+	return fmt.Sprintf("msg-%d", index)
 }
 
 func checkForQuote(quoteType quoteType) string {
@@ -113,31 +295,40 @@ func checkForQuote(quoteType quoteType) string {
 
 	msg, err := decodeBase64ToString(quoteType.QuoteMessageContext)
 	if err != nil {
-		fmt.Println("Error decoding base64 to string:", err)
+		slog.Error("decoding base64 to string failed", "err", err)
 	}
 
-	quoteString := fmt.Sprintf("%s[#997275]┌ [%s - %s: %s]\n", margin, quoteType.QuoteTime,
-		getUsernameForID(quoteType.QuoteClientID), msg)
+	quoteString := fmt.Sprintf("%s%s%s[%s - %s: %s]\n", margin, colorTag(currentTheme.Quote), getMarkers().quote, formatQuoteClock(quoteType),
+		tview.Escape(getUsernameForID(quoteType.QuoteClientID)), tview.Escape(strings.ReplaceAll(msg, "\n", " ")))
 
 	return quoteString
 }
 
 // checkForReactions checks for reactions in a given list of reaction types and returns a formatted string representing the reactions.
-// If the input is empty, it returns an empty string. The reactions are formatted as "[#8B8000]└ [<reaction1> <reaction2> ...][-]".
-// The 'margin' constant represents the indentation for the formatted string.
-// The 'ReactionType' struct defines the structure of a reaction and is not included in this documentation.
-// The function uses the 'strings.Builder' type to build the formatted string efficiently.
+// If the input is empty, it returns an empty string. The reactions are written on a new line indented by margin as
+// "<marker>[ <reaction1> <reaction2> ... ]", colored with the reaction color of the current theme. The marker is the
+// reactions marker of getMarkers, "└ " or "reactions: " in plain mode.
+// Identical reactions of the same client are shown once, removed reactions are left out and reactions of several clients
+// are counted, e.g. "👍×2". Reactions of this client are highlighted.
 // Returns the formatted string representing the reactions or an empty string if an error occurs.
 func checkForReactions(reactionType []reactionType) string {
-	if len(reactionType) == 0 {
+	summaries := summarizeReactions(reactionType, envVars.ID)
+	if len(summaries) == 0 {
 		return ""
 	}
 
 	var reactions strings.Builder
 
-	reactions.WriteString("\n" + margin + "[#8B8000]└ [")
-	for _, reaction := range reactionType {
-		_, err := fmt.Fprintf(&reactions, " %s", reaction.ReactionContext)
+	reactions.WriteString("\n" + margin + colorTag(currentTheme.Reaction) + getMarkers().reactions + "[")
+	for _, summary := range summaries {
+		content := tview.Escape(summary.content)
+		if summary.count > 1 {
+			content += fmt.Sprintf("×%d", summary.count)
+		}
+		if summary.own {
+			content = "[::r]" + content + "[::-]"
+		}
+		_, err := fmt.Fprintf(&reactions, " %s", content)
 		if err != nil {
 			return ""
 		}
@@ -147,176 +338,138 @@ func checkForReactions(reactionType []reactionType) string {
 	return reactions.String()
 }
 
-// checks for [000] > or [000] >> in the message
-func evalTextInChatView(text string) int {
-	regexPattern := `^\[([0-9]{3})\] (>{1,2}) `
-	re := regexp.MustCompile(regexPattern)
-
-	matches := re.FindStringSubmatch(text)
-	if matches != nil {
-		switch matches[2] {
-		case ">":
-			// quote
-			return 1
-		case ">>":
-			// reaction
-			return 2
-		default:
-			return 0
-		}
-	} else {
-		return 0
-	}
-}
-
 // createInputField creates a new tview.InputField with a specific label, label color, and field background color.
 // It also sets up event handlers for text changes and when the enter key is pressed.
-// The input field's field text color is set based on the evaluation of the input text using evalTextInChatView.
+// The input field's field text color is set according to the command being typed, see updateInputStyle.
 // It returns the created input field.
 func createInputField(app *app) *tview.InputField {
 	customInputField := tview.NewInputField()
 	inputField = customInputField.
 		SetLabel(message).
-		SetLabelColor(tcell.ColorGreenYellow).
-		SetFieldBackgroundColor(tcell.ColorBlueViolet).
+		SetLabelColor(themeColor(currentTheme.InputLabel)).
+		SetFieldBackgroundColor(themeColor(currentTheme.InputBackground)).
 		SetChangedFunc(func(text string) {
-			textCase := evalTextInChatView(text)
-
-			if textCase == 0 {
-				textCase = evalTextInChatViewV2(text)
-			}
-
-			if textCase == 0 {
-				textCase = evalTextInChatViewV3(text)
-			}
-
-			switch textCase {
-			case 1:
-				// quote
-				customInputField.SetFieldBackgroundColor(tcell.ColorDarkSlateGray)
-				customInputField.SetFieldTextColor(tcell.ColorDarkOrange)
-				changeTextLabelText(quote)
-			case 2:
-				// reaction
-				customInputField.SetFieldBackgroundColor(tcell.ColorDarkSlateGray)
-				customInputField.SetFieldTextColor(tcell.ColorGreen)
-				changeTextLabelText(reaction)
-			case 3:
-				// settings change
-				customInputField.SetFieldBackgroundColor(tcell.ColorDarkSlateGray)
-				customInputField.SetFieldTextColor(tcell.ColorYellow)
-				changeTextLabelText(setting)
-
-			default:
-				customInputField.SetFieldBackgroundColor(tcell.ColorBlueViolet)
-				customInputField.SetFieldTextColor(tcell.ColorWhite)
-				changeTextLabelText(message)
-			}
+			clearInputHint()
+			updateInputStyle(customInputField, text)
 		}).
 		SetDoneFunc(func(key tcell.Key) {
 			if key == tcell.KeyEnter {
-
-				textInput := customInputField.GetText()
-
-				// check for [000] > or [000] >> in the message
-				textCase := evalTextInChatView(textInput)
-
-				if textCase != 0 {
-					switch textCase {
-					case 1:
-						// quote
-						sendQuotedMessagePayloadToWebsocket(app.conn, &textInput)
-					case 2:
-						// reaction
-						sendReactionPayloadToWebsocket(app.conn, &textInput)
-					default:
-						// plain message
-						sendMessagePayloadToWebsocket(app.conn, &textInput)
-					}
-
-					customInputField.SetText("")
+				// invalid commands are kept for correction
+				text := customInputField.GetText()
+				if err := app.submitInput(text); err != nil {
+					showInputHint(err.Error())
 					return
 				}
-
-				// check for /q or /r followed by three digits and a space
-				textCaseV2 := evalTextInChatViewV2(textInput)
-				if textCaseV2 != 0 {
-					switch textCaseV2 {
-					case 1:
-						sendQuotedMessagePayloadToWebsocketV2(app.conn, &textInput)
-					// quote
-					case 2:
-						sendReactionPayloadToWebsocketV2(app.conn, &textInput)
-					// reaction
-					default:
-						// plain message
-						sendMessagePayloadToWebsocket(app.conn, &textInput)
-					}
-					customInputField.SetText("")
-					return
+				if err := history.add(text); err != nil {
+					slog.Warn("writing input history failed", "err", err)
 				}
+				customInputField.SetText("")
+			}
+		})
 
-				textCaseV3 := evalTextInChatViewV3(textInput)
+	customInputField.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// Up/Down, Ctrl+R and emacs-style keys, see history.go
+		if event = handleHistoryKey(customInputField, event); event == nil {
+			return nil
+		}
 
-				if textCaseV3 != 0 {
-					switch textCaseV3 {
-					case 4:
-						// settings change
-						sendProfileUpdateToWebsocket(app.conn, &textInput)
-					case 5:
-					// settings change
-					default:
-						// plain message
-						sendMessagePayloadToWebsocket(app.conn, &textInput)
+		// Escape selects messages in the chat view
+		if event.Key() == tcell.KeyEscape {
+			app.enterSelectionMode()
+			return nil
+		}
 
-						customInputField.SetText("")
+		// Alt+Enter continues the message in the multi-line composer
+		if event.Key() == tcell.KeyEnter && event.Modifiers()&tcell.ModAlt != 0 {
+			text := customInputField.GetText()
+			customInputField.SetText("")
+			if text != "" {
+				text += "\n"
+			}
+			app.showComposer(text)
+			return nil
+		}
 
-					}
-					customInputField.SetText("")
-					return
+		if event.Key() == tcell.KeyTab {
+			text := customInputField.GetText()
+
+			// complete /commands and their arguments
+			if completed, candidates, ok := completeCommand(text); ok || len(candidates) > 1 {
+				customInputField.SetText(completed)
+				if len(candidates) > 1 {
+					showInputHint(strings.Join(candidates, "  "))
 				}
+				return nil
+			}
 
-				sendMessagePayloadToWebsocket(app.conn, &textInput)
-				customInputField.SetText("")
+			// complete @username
+			if completed, ok := completeMention(text, getClientUsernames()); ok {
+				customInputField.SetText(completed)
+				return nil
 			}
-		})
 
-	// inputField.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-	// 	if event.Key() == tcell.KeyF1 {
-	// 		fmt.Println("F1 pressed")
-	// 		app.ui.SetFocus(modal)
-	//
-	// 		return nil
-	// 	}
-	// 	return event
-	// })
+			// complete :shortcode to its emoji
+			if completed, ok := completeShortcode(text); ok {
+				customInputField.SetText(completed)
+			}
+			return nil
+		}
+		return event
+	})
 
 	return customInputField
 }
 
-func sendProfileUpdateToWebsocket(conn *websocket.Conn, message *string) {
-	// schema: /sc
+// updateInputStyle colors the input field and sets its label according to the kind of the command
+// being typed, e.g. a quote, a reaction or a settings change, see getInputKind.
+func updateInputStyle(input *tview.InputField, text string) {
+	switch getInputKind(text) {
+	case inputKindQuote:
+		input.SetFieldBackgroundColor(themeColor(currentTheme.CommandBackground))
+		input.SetFieldTextColor(themeColor(currentTheme.QuoteInput))
+		input.SetLabel(quote)
+	case inputKindReaction:
+		input.SetFieldBackgroundColor(themeColor(currentTheme.CommandBackground))
+		input.SetFieldTextColor(themeColor(currentTheme.ReactionInput))
+		input.SetLabel(reaction)
+	case inputKindSetting:
+		input.SetFieldBackgroundColor(themeColor(currentTheme.CommandBackground))
+		input.SetFieldTextColor(themeColor(currentTheme.SettingInput))
+		input.SetLabel(setting)
+	default:
+		input.SetFieldBackgroundColor(themeColor(currentTheme.InputBackground))
+		input.SetFieldTextColor(themeColor(currentTheme.InputText))
+		input.SetLabel(message)
+	}
+}
 
-	// remove the first 4 characters
-	trimmedMessage := (*message)[4:]
+// submitInput executes a slash command or sends the text as message. The legacy forms
+// "[000] > text" and "[000] >> reaction" are still supported. Invalid commands are returned as error.
+func (app *app) submitInput(textInput string) error {
+	// slash commands, see commands.go
+	isCommand, err := executeCommand(app, translateLegacyInput(textInput))
+	if err != nil || isCommand {
+		return err
+	}
 
-	checkIfTrimmedMessageIsAHexColor := checkIfHexColor(trimmedMessage)
+	// plain message, a leading // sends a message starting with /
+	if strings.HasPrefix(textInput, "//") {
+		textInput = textInput[1:]
+	}
+	// :shortcodes: like :+1: are replaced with their emoji
+	return app.sendMessagePayload(newMessagePayload(expandShortcodes(textInput)))
+}
 
+// newProfileUpdatePayload builds the payload changing the color of this client
+func newProfileUpdatePayload(color string) clientUpdatePayload {
 	thisClient := getThisClient()
 
-	if checkIfTrimmedMessageIsAHexColor {
-		profileUpdatePayload := clientUpdatePayload{
-			PayloadType:        3,
-			ClientDbID:         thisClient.ClientDbID,
-			ClientColor:        trimmedMessage,
-			ClientUsername:     thisClient.ClientUsername,
-			ClientProfileImage: thisClient.ClientProfileImage,
-		}
-
-		err := conn.WriteJSON(profileUpdatePayload)
-		if err != nil {
-			fmt.Println("Error writing messagePayload:", err)
-		}
+	return clientUpdatePayload{
+		PayloadType:        3,
+		ClientDbID:         thisClient.ClientDbID,
+		ClientColor:        color,
+		ClientUsername:     thisClient.ClientUsername,
+		ClientProfileImage: thisClient.ClientProfileImage,
 	}
 }
 
@@ -329,103 +482,30 @@ func checkIfHexColor(trimmedMessage string) bool {
 	return matches != nil
 }
 
-// checks for /q or /r followed by three digits and a space
-func evalTextInChatViewV2(text string) int {
-	regexPattern := `^/(q|r)[0-9]{3} `
-	re := regexp.MustCompile(regexPattern)
-
-	matches := re.FindStringSubmatch(text)
-	if matches != nil {
-		switch matches[1] {
-		case "q":
-			return 1
-		case "r":
-			return 2
-		default:
-			return 0
-		}
-	} else {
-		return 0
-	}
-}
-
-// checks for /s followed by three digits and a space
-func evalTextInChatViewV3(text string) int {
-	regexPattern := `^/s[n,c][0-9]{3} `
-	re := regexp.MustCompile(regexPattern)
-
-	matches := re.FindStringSubmatch(text)
-	if matches != nil {
-		return 3
-	}
-
-	return 0
-}
-
-func sendReactionPayloadToWebsocketV2(conn *websocket.Conn, message *string) {
-	// schema: /r005
-
-	// grab characters in brackets
-	trimmedMessageIndex := (*message)[2:5]
-	reactedMessagePayload := getMessageFromCache(atoi(trimmedMessageIndex))
-	// remove the first 5 characters
-	trimmedMessage := (*message)[6:]
-
-	reactionPayload := reactionPayload{
+// newReactionPayload builds the payload of a reaction of this client to the message with the given id
+func newReactionPayload(messageDbID string, reaction string) reactionPayload {
+	return reactionPayload{
 		PayloadType:       7,
 		ReactionDbID:      uuid.New().String(),
-		ReactionMessageID: reactedMessagePayload.MessageType.MessageDbID,
-		ReactionContext:   trimmedMessage,
+		ReactionMessageID: messageDbID,
+		ReactionContext:   reaction,
 		ReactionClientID:  envVars.ID,
 	}
-
-	err := conn.WriteJSON(reactionPayload)
-	if err != nil {
-		fmt.Println("Error writing messagePayload:", err)
-	}
 }
 
-func sendReactionPayloadToWebsocket(conn *websocket.Conn, message *string) {
-	// schema: [000] >>
-
-	// grab characters in brackets
-	trimmedMessageIndex := (*message)[1:4]
-	reactedMessagePayload := getMessageFromCache(atoi(trimmedMessageIndex))
-	// remove the first 8 characters
-	trimmedMessage := (*message)[8:]
-
-	reactionPayload := reactionPayload{
-		PayloadType:       7,
-		ReactionDbID:      uuid.New().String(),
-		ReactionMessageID: reactedMessagePayload.MessageType.MessageDbID,
-		ReactionContext:   trimmedMessage,
-		ReactionClientID:  envVars.ID,
-	}
-
-	err := conn.WriteJSON(reactionPayload)
-	if err != nil {
-		fmt.Println("Error writing messagePayload:", err)
-	}
-}
-
-func sendQuotedMessagePayloadToWebsocketV2(conn *websocket.Conn, message *string) {
-	// schema: /q000
-
-	// grab characters in brackets
-	trimmedMessageIndex := (*message)[2:5]
-	quotedMessagePayload := getMessageFromCache(atoi(trimmedMessageIndex))
-	// remove the first 5 characters
-	trimmedMessage := (*message)[5:]
-
-	messagePayload := messagePayload{
+// newQuotedMessagePayload builds the payload of a message of this client quoting another message
+func newQuotedMessagePayload(quotedMessagePayload messagePayload, message string) messagePayload {
+	timestamp, date, clock := newTimestamps(time.Now())
+	return messagePayload{
 		PayloadType: 1,
 		MessageType: messageType{
 			MessageDbID:    GenerateRandomID(),
 			Deleted:        false,
 			Edited:         false,
-			MessageContext: base64.StdEncoding.EncodeToString([]byte(trimmedMessage)),
-			MessageTime:    time.Now().Format("15:04"),
-			MessageDate:    time.Now().Format("2006-01-02"),
+			MessageContext: base64.StdEncoding.EncodeToString([]byte(message)),
+			MessageTime:    clock,
+			MessageDate:    date,
+			Timestamp:      timestamp,
 		},
 		ClientType: clientType{
 			ClientDbID: envVars.ID,
@@ -436,15 +516,11 @@ func sendQuotedMessagePayloadToWebsocketV2(conn *websocket.Conn, message *string
 			QuoteMessageContext: quotedMessagePayload.MessageType.MessageContext,
 			QuoteTime:           quotedMessagePayload.MessageType.MessageTime,
 			QuoteDate:           quotedMessagePayload.MessageType.MessageDate,
+			QuoteTimestamp:      quotedMessagePayload.MessageType.Timestamp,
 		},
 		ReactionType: nil,
 		ImageType:    nil,
 	}
-
-	err := conn.WriteJSON(messagePayload)
-	if err != nil {
-		fmt.Println("Error writing messagePayload:", err)
-	}
 }
 
 func GenerateRandomID() string {
@@ -453,45 +529,6 @@ func GenerateRandomID() string {
 	return base64.StdEncoding.EncodeToString(b)
 }
 
-func sendQuotedMessagePayloadToWebsocket(conn *websocket.Conn, message *string) {
-	// schema: [000] >
-
-	// grab characters in brackets
-	trimmedMessageIndex := (*message)[1:4]
-	quotedMessagePayload := getMessageFromCache(atoi(trimmedMessageIndex))
-	// remove the first 7 characters
-	trimmedMessage := (*message)[7:]
-
-	messagePayload := messagePayload{
-		PayloadType: 1,
-		MessageType: messageType{
-			MessageDbID:    GenerateRandomID(),
-			Deleted:        false,
-			Edited:         false,
-			MessageContext: base64.StdEncoding.EncodeToString([]byte(trimmedMessage)),
-			MessageTime:    time.Now().Format("15:04"),
-			MessageDate:    time.Now().Format("2006-01-02"),
-		},
-		ClientType: clientType{
-			ClientDbID: envVars.ID,
-		},
-		QuoteType: &quoteType{
-			QuoteDbID:           quotedMessagePayload.MessageType.MessageDbID,
-			QuoteClientID:       quotedMessagePayload.ClientType.ClientDbID,
-			QuoteMessageContext: quotedMessagePayload.MessageType.MessageContext,
-			QuoteTime:           quotedMessagePayload.MessageType.MessageTime,
-			QuoteDate:           quotedMessagePayload.MessageType.MessageDate,
-		},
-		ReactionType: nil,
-		ImageType:    nil,
-	}
-
-	err := conn.WriteJSON(messagePayload)
-	if err != nil {
-		fmt.Println("Error writing messagePayload:", err)
-	}
-}
-
 func atoi(index string) int {
 	i, err := strconv.Atoi(index)
 	if err != nil {
@@ -503,16 +540,25 @@ func atoi(index string) int {
 
 func (app *app) clearChatView() {
 	chatView.Clear()
+	lastRenderedDay = ""
 }
 
 func createFlex(app *app) tview.Flex {
 	flex := tview.NewFlex()
-	inputField := createInputField(app)
+	input := &pasteInputField{InputField: createInputField(app)}
+	input.onMultilinePaste = func(text string) {
+		app.showComposer(input.GetText() + text)
+		input.SetText("")
+	}
 	typingView = createTypingView(app)
+	statusView = createStatusView()
+	statusBar := tview.NewFlex().
+		AddItem(typingView, 0, 1, false).
+		AddItem(statusView, 0, 1, false)
 	flex.SetDirection(tview.FlexRow)
-	flex.AddItem(chatView, 0, 1, false)
-	flex.AddItem(inputField, 1, 1, true)
-	flex.AddItem(typingView, 1, 1, false)
+	flex.AddItem(createChatRow(), 0, 1, false)
+	flex.AddItem(input, 1, 1, true)
+	flex.AddItem(statusBar, 1, 1, false)
 
 	return *flex
 }
@@ -537,19 +583,69 @@ func createFlex(app *app) tview.Flex {
 // 	return modal
 // }
 
+// showOverlay shows the given primitive centered on top of the chat and focuses it.
+func (app *app) showOverlay(name string, primitive tview.Primitive) {
+	overlay := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(primitive, 0, 8, true).
+			AddItem(nil, 0, 1, false), 0, 8, true).
+		AddItem(nil, 0, 1, false)
+
+	pages.AddPage(name, overlay, true, true)
+	app.ui.SetFocus(primitive)
+}
+
+// hideOverlay removes the overlay with the given name and gives the focus back to the input field.
+func (app *app) hideOverlay(name string) {
+	pages.RemovePage(name)
+	app.ui.SetFocus(inputField)
+}
+
 func gui(app *app) error {
+	// the theme sets the default colors of the widgets, so it is applied before they are created
+	plainMode = getEnvPlain()
+	if plainMode {
+		applyTheme(plainTheme())
+	} else {
+		applyTheme(loadConfiguredTheme())
+	}
+
 	chatView = createChatView(app)
 	flex = createFlex(app)
+	pages = tview.NewPages().
+		AddPage(mainPageName, &flex, true, true)
+
+	if historyDir, err := getHistoryDir(); err == nil {
+		if history, err = loadInputHistory(filepath.Join(historyDir, "history.jsonl")); err != nil {
+			writeSystemLine(fmt.Sprintf("input history could not be loaded: %v", err))
+		}
+	}
+	inputField.SetText(loadDraft())
+
+	if err := startProcessPlugins(app); err != nil {
+		// the errors of several plugins are joined, each one gets its own line
+		for _, line := range strings.Split(err.Error(), "\n") {
+			writeSystemLine(line)
+		}
+	}
+
+	// show the archived history until the server sends the current one
+	if len(getMessagesFromCache()) == 0 {
+		app.showMessageList(app.archive.getLastMessages(100))
+	}
 	// modal = createModal(app)
 
+	// key bindings like Ctrl+F for the search, see keymap.go
+	activeKeymap = loadConfiguredKeymap()
 	app.ui.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		event = app.handleKeymapKey(event)
+		app.markVisibleAsRead()
 		return event
 	})
+	app.ui.SetAfterDrawFunc(trackChatEnd)
 
-	if err := app.ui.SetRoot(&flex,
-		true).EnableMouse(true).Run(); err != nil {
-		panic(err)
-	}
-
-	return nil
+	return app.ui.SetRoot(pages,
+		true).EnableMouse(true).EnablePaste(true).Run()
 }
@@ -44,48 +44,6 @@ func TestCheckForReactions(t *testing.T) {
 	}
 }
 
-func TestEvalTextInChatView(t *testing.T) {
-	tests := []struct {
-		name     string
-		text     string
-		expected int
-	}{
-		{
-			name:     "NoMatches",
-			text:     "[090] abc ",
-			expected: 0,
-		},
-		{
-			name:     "SingleGT",
-			text:     "[123] > ",
-			expected: 1,
-		},
-		{
-			name:     "DoubleGT",
-			text:     "[234] >> ",
-			expected: 2,
-		},
-		{
-			name:     "DifferentNumber",
-			text:     "[345] >> ",
-			expected: 2,
-		},
-		{
-			name:     "InvalidString",
-			text:     "invalid text",
-			expected: 0,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := evalTextInChatView(tt.text); got != tt.expected {
-				t.Errorf("evalTextInChatView() = %v, want %v", got, tt.expected)
-			}
-		})
-	}
-}
-
 func TestCheckIfHexColor(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -115,86 +73,6 @@ func TestCheckIfHexColor(t *testing.T) {
 	}
 }
 
-func TestEvalTextInChatViewV2(t *testing.T) {
-	tests := []struct {
-		name string
-		text string
-		want int
-	}{
-		{
-			name: "regex match 'q'",
-			text: "/q123 hello",
-			want: 1,
-		},
-		{
-			name: "regex match 'r'",
-			text: "/r999 hello",
-			want: 2,
-		},
-		{
-			name: "regex misMatch",
-			text: "/p189 what's up",
-			want: 0,
-		},
-		{
-			name: "empty input",
-			text: "",
-			want: 0,
-		},
-		{
-			name: "regex match without following space",
-			text: "/r435hello",
-			want: 0,
-		},
-		{
-			name: "regex match but no digit",
-			text: "/r hello",
-			want: 0,
-		},
-		{
-			name: "text without slash",
-			text: "hello world",
-			want: 0,
-		},
-		{
-			name: "text with too many digits",
-			text: "/q1234 hello",
-			want: 0,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := evalTextInChatViewV2(tt.text); got != tt.want {
-				t.Errorf("evalTextInChatViewV2() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestEvalTextInChatViewV3(t *testing.T) {
-	tests := []struct {
-		name string
-		text string
-		want int
-	}{
-		{name: "MatchFound", text: "/sn233 Hello", want: 3},
-		{name: "MatchNotFound", text: "/shello", want: 0},
-		{name: "EmptyString", text: "", want: 0},
-		{name: "NumericContent", text: "/s123456", want: 0},
-		{name: "SpecialCharacterContent", text: "/s@#!$%^&*(", want: 0},
-		{name: "WhitespaceContent", text: " ", want: 0},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := evalTextInChatViewV3(tt.text); got != tt.want {
-				t.Errorf("evalTextInChatViewV3() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestAtoi(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -355,26 +233,6 @@ func Test_checkForReactions(t *testing.T) {
 	}
 }
 
-func Test_evalTextInChatView(t *testing.T) {
-	type args struct {
-		text string
-	}
-	tests := []struct {
-		name string
-		args args
-		want int
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := evalTextInChatView(tt.args.text); got != tt.want {
-				t.Errorf("evalTextInChatView() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func Test_createInputField(t *testing.T) {
 	type args struct {
 		app *app
@@ -395,24 +253,6 @@ func Test_createInputField(t *testing.T) {
 	}
 }
 
-func Test_sendProfileUpdateToWebsocket(t *testing.T) {
-	type args struct {
-		conn    *websocket.Conn
-		message *string
-	}
-	tests := []struct {
-		args args
-		name string
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sendProfileUpdateToWebsocket(tt.args.conn, tt.args.message)
-		})
-	}
-}
-
 func Test_checkIfHexColor(t *testing.T) {
 	type args struct {
 		trimmedMessage string
@@ -433,172 +273,6 @@ func Test_checkIfHexColor(t *testing.T) {
 	}
 }
 
-func Test_evalTextInChatViewV2(t *testing.T) {
-	type args struct {
-		text string
-	}
-	tests := []struct {
-		name string
-		args args
-		want int
-	}{
-		{
-			name: "invalid, return 0",
-			args: args{
-				text: "hello",
-			},
-			want: 0,
-		},
-		{
-			name: "valid q, return 1",
-			args: args{
-				text: "/q123 hello",
-			},
-			want: 1,
-		},
-		{
-			name: "valid r, return 2",
-			args: args{
-				text: "/r999 hello",
-			},
-			want: 2,
-		},
-		{
-			name: "invalid q, less than 3 digits, return 0",
-			args: args{
-				text: "/q12 hello",
-			},
-			want: 0,
-		},
-		{
-			name: "invalid r, less than 3 digits, return 2",
-			args: args{
-				text: "/r99 hello",
-			},
-			want: 0,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := evalTextInChatViewV2(tt.args.text); got != tt.want {
-				t.Errorf("evalTextInChatViewV2() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func Test_evalTextInChatViewV3(t *testing.T) {
-	type args struct {
-		text string
-	}
-	tests := []struct {
-		name string
-		args args
-		want int
-	}{
-		{
-			name: "MatchFound",
-			args: args{
-				text: "/sn333 Hello",
-			},
-			want: 3,
-		},
-		{
-			name: "MatchNotFound",
-			args: args{
-				text: "/shello",
-			},
-			want: 0,
-		},
-		{
-			name: "MatchNotFound",
-			args: args{
-				text: "/s22",
-			},
-			want: 0,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := evalTextInChatViewV3(tt.args.text); got != tt.want {
-				t.Errorf("evalTextInChatViewV3() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func Test_sendReactionPayloadToWebsocketV2(t *testing.T) {
-	type args struct {
-		conn    *websocket.Conn
-		message *string
-	}
-	tests := []struct {
-		args args
-		name string
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sendReactionPayloadToWebsocketV2(tt.args.conn, tt.args.message)
-		})
-	}
-}
-
-func Test_sendReactionPayloadToWebsocket(t *testing.T) {
-	type args struct {
-		conn    *websocket.Conn
-		message *string
-	}
-	tests := []struct {
-		args args
-		name string
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sendReactionPayloadToWebsocket(tt.args.conn, tt.args.message)
-		})
-	}
-}
-
-func Test_sendQuotedMessagePayloadToWebsocketV2(t *testing.T) {
-	type args struct {
-		conn    *websocket.Conn
-		message *string
-	}
-	tests := []struct {
-		args args
-		name string
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sendQuotedMessagePayloadToWebsocketV2(tt.args.conn, tt.args.message)
-		})
-	}
-}
-
-func Test_sendQuotedMessagePayloadToWebsocket(t *testing.T) {
-	type args struct {
-		conn    *websocket.Conn
-		message *string
-	}
-	tests := []struct {
-		args args
-		name string
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sendQuotedMessagePayloadToWebsocket(tt.args.conn, tt.args.message)
-		})
-	}
-}
-
 func Test_atoi(t *testing.T) {
 	type args struct {
 		index string
@@ -682,3 +356,18 @@ func Test_gui(t *testing.T) {
 		})
 	}
 }
+
+func TestReplaceRegions(t *testing.T) {
+	text := "── Today ──\n[\"msg-0\"]first[\"\"]\n[\"msg-1\"]second [gray]⋯[-][\"\"]\n[\"msg-10\"]tenth[\"\"]\n"
+
+	got := replaceRegions(text, map[string]string{
+		"msg-1":  "second [gray]✓[-]",
+		"msg-10": "edited",
+		"msg-99": "missing",
+	})
+
+	want := "── Today ──\n[\"msg-0\"]first[\"\"]\n[\"msg-1\"]second [gray]✓[-][\"\"]\n[\"msg-10\"]edited[\"\"]\n"
+	if got != want {
+		t.Errorf("replaceRegions() = %q, want %q", got, want)
+	}
+}
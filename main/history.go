@@ -0,0 +1,307 @@
+// main package
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	// maxHistoryEntries is the number of inputs kept in the history file
+	maxHistoryEntries = 1000
+	reverseSearch     = "  Search: "
+)
+
+// inputHistory holds the sent inputs, oldest first. Entries are navigated with Up and Down,
+// the text typed before navigating is kept as draft and restored after the newest entry.
+type inputHistory struct {
+	entries  []string
+	draft    string
+	path     string
+	position int
+}
+
+var history = &inputHistory{}
+
+// getHistoryDir returns the directory of the input history and the draft.
+func getHistoryDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".localchat", "history"), nil
+}
+
+// loadInputHistory reads the history file, one JSON string per line so entries may contain
+// line breaks. A missing file results in an empty history. The file is rewritten if it holds
+// more than maxHistoryEntries entries.
+func loadInputHistory(path string) (*inputHistory, error) {
+	ih := &inputHistory{path: path}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ih, nil
+	}
+	if err != nil {
+		return ih, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		ih.entries = append(ih.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return ih, err
+	}
+
+	if len(ih.entries) > maxHistoryEntries {
+		ih.entries = ih.entries[len(ih.entries)-maxHistoryEntries:]
+		if err := ih.rewrite(); err != nil {
+			return ih, err
+		}
+	}
+	ih.position = len(ih.entries)
+
+	return ih, nil
+}
+
+// rewrite writes all entries to the history file.
+func (ih *inputHistory) rewrite() error {
+	var builder strings.Builder
+	for _, entry := range ih.entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		builder.Write(line)
+		builder.WriteByte('\n')
+	}
+
+	return os.WriteFile(ih.path, []byte(builder.String()), 0o600)
+}
+
+// add appends the entry to the history and the history file and ends the navigation.
+// Empty entries and repetitions of the last entry are skipped.
+func (ih *inputHistory) add(entry string) error {
+	ih.position = len(ih.entries)
+	ih.draft = ""
+	if strings.TrimSpace(entry) == "" || (len(ih.entries) > 0 && ih.entries[len(ih.entries)-1] == entry) {
+		return nil
+	}
+
+	ih.entries = append(ih.entries, entry)
+	if len(ih.entries) > maxHistoryEntries {
+		ih.entries = ih.entries[1:]
+	}
+	ih.position = len(ih.entries)
+
+	if ih.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(ih.path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(ih.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(entry)
+}
+
+// previous returns the entry before the current one. The current text is kept as draft when
+// the navigation starts. It returns false if there is no older entry.
+func (ih *inputHistory) previous(current string) (string, bool) {
+	if ih.position == 0 {
+		return "", false
+	}
+	if ih.position == len(ih.entries) {
+		ih.draft = current
+	}
+	ih.position--
+	return ih.entries[ih.position], true
+}
+
+// next returns the entry after the current one, or the draft after the newest entry.
+// It returns false if the history is not navigated.
+func (ih *inputHistory) next() (string, bool) {
+	if ih.position >= len(ih.entries) {
+		return "", false
+	}
+	ih.position++
+	if ih.position == len(ih.entries) {
+		return ih.draft, true
+	}
+	return ih.entries[ih.position], true
+}
+
+// search returns the index of the newest entry before the index that contains the term.
+func (ih *inputHistory) search(term string, before int) (int, bool) {
+	term = strings.ToLower(term)
+	for i := min(before, len(ih.entries)) - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(ih.entries[i]), term) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// getDraftFilePath returns the file the unsent input is kept in between sessions.
+func getDraftFilePath() (string, error) {
+	historyDir, err := getHistoryDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(historyDir, "draft.txt"), nil
+}
+
+// loadDraft returns the input left unsent in the last session.
+func loadDraft() string {
+	draftFilePath, err := getDraftFilePath()
+	if err != nil {
+		slog.Warn("retrieving home path failed", "err", err)
+		return ""
+	}
+
+	draft, err := os.ReadFile(draftFilePath)
+	if err != nil {
+		return ""
+	}
+
+	return string(draft)
+}
+
+// saveDraft keeps the unsent input for the next session, an empty draft removes the file.
+func saveDraft(draft string) error {
+	draftFilePath, err := getDraftFilePath()
+	if err != nil {
+		return err
+	}
+
+	if draft == "" {
+		if err := os.Remove(draftFilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(draftFilePath), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(draftFilePath, []byte(draft), 0o600)
+}
+
+// historySearchState is the state of a reverse search started with Ctrl+R.
+type historySearchState struct {
+	term     string
+	original string
+	index    int
+	active   bool
+}
+
+var historySearch historySearchState
+
+// handleHistoryKey handles history navigation, reverse search and the emacs-style keys not
+// provided by tview. It returns nil if the event was handled.
+func handleHistoryKey(input *tview.InputField, event *tcell.EventKey) *tcell.EventKey {
+	if historySearch.active {
+		return handleReverseSearchKey(input, event)
+	}
+
+	forward := func(key tcell.Key, r rune, mod tcell.ModMask) {
+		input.InputHandler()(tcell.NewEventKey(key, r, mod), func(tview.Primitive) {})
+	}
+
+	switch {
+	case event.Key() == tcell.KeyUp, event.Key() == tcell.KeyCtrlP:
+		if entry, ok := history.previous(input.GetText()); ok {
+			input.SetText(entry)
+		}
+		return nil
+	case event.Key() == tcell.KeyDown, event.Key() == tcell.KeyCtrlN:
+		if entry, ok := history.next(); ok {
+			input.SetText(entry)
+		}
+		return nil
+	case event.Key() == tcell.KeyCtrlR:
+		historySearch = historySearchState{active: true, original: input.GetText(), index: len(history.entries)}
+		input.SetLabel(reverseSearch)
+		showInputHint("type to search the input history, Ctrl+R for older matches, Escape cancels")
+		return nil
+	case event.Key() == tcell.KeyCtrlB:
+		forward(tcell.KeyLeft, 0, tcell.ModNone)
+		return nil
+	case event.Key() == tcell.KeyCtrlF:
+		forward(tcell.KeyRight, 0, tcell.ModNone)
+		return nil
+	case event.Key() == tcell.KeyRune && event.Modifiers()&tcell.ModAlt != 0 && event.Rune() == 'd':
+		// kill the next word: move to its end and delete it backwards
+		forward(tcell.KeyRune, 'f', tcell.ModAlt)
+		forward(tcell.KeyCtrlW, 0, tcell.ModCtrl)
+		return nil
+	}
+
+	return event
+}
+
+// handleReverseSearchKey handles a key while searching the history. Typing refines the search,
+// Ctrl+R finds older matches, Escape restores the original input and any other key accepts the
+// match and is processed as usual, e.g. Enter sends it.
+func handleReverseSearchKey(input *tview.InputField, event *tcell.EventKey) *tcell.EventKey {
+	update := func(term string, before int) {
+		if index, ok := history.search(term, before); ok {
+			historySearch.index = index
+			input.SetText(history.entries[index])
+			clearInputHint()
+		} else {
+			showInputHint("no match for " + term)
+		}
+		historySearch.term = term
+		input.SetLabel(reverseSearch)
+	}
+
+	switch event.Key() {
+	case tcell.KeyRune:
+		if event.Modifiers()&tcell.ModAlt == 0 {
+			update(historySearch.term+string(event.Rune()), historySearch.index+1)
+			return nil
+		}
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		term := []rune(historySearch.term)
+		if len(term) > 0 {
+			update(string(term[:len(term)-1]), len(history.entries))
+		}
+		return nil
+	case tcell.KeyCtrlR:
+		update(historySearch.term, historySearch.index)
+		return nil
+	case tcell.KeyEscape, tcell.KeyCtrlG:
+		historySearch.active = false
+		input.SetText(historySearch.original)
+		updateInputStyle(input, input.GetText())
+		clearInputHint()
+		return nil
+	}
+
+	historySearch.active = false
+	updateInputStyle(input, input.GetText())
+	clearInputHint()
+	return event
+}
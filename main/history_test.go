@@ -0,0 +1,156 @@
+// main package
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInputHistory_Navigation(t *testing.T) {
+	ih := &inputHistory{}
+	assert.NoError(t, ih.add("first"))
+	assert.NoError(t, ih.add("second"))
+	assert.NoError(t, ih.add("second"))
+	assert.NoError(t, ih.add("  "))
+	assert.Equal(t, []string{"first", "second"}, ih.entries)
+
+	_, ok := ih.next()
+	assert.False(t, ok)
+
+	entry, ok := ih.previous("draft")
+	assert.True(t, ok)
+	assert.Equal(t, "second", entry)
+	entry, _ = ih.previous(entry)
+	assert.Equal(t, "first", entry)
+	_, ok = ih.previous(entry)
+	assert.False(t, ok)
+
+	entry, _ = ih.next()
+	assert.Equal(t, "second", entry)
+	entry, ok = ih.next()
+	assert.True(t, ok)
+	assert.Equal(t, "draft", entry)
+}
+
+func TestInputHistory_Search(t *testing.T) {
+	ih := &inputHistory{entries: []string{"/search build", "hello", "build is red"}}
+
+	index, ok := ih.search("BUILD", len(ih.entries))
+	assert.True(t, ok)
+	assert.Equal(t, 2, index)
+
+	index, ok = ih.search("build", index)
+	assert.True(t, ok)
+	assert.Equal(t, 0, index)
+
+	_, ok = ih.search("build", index)
+	assert.False(t, ok)
+}
+
+func TestLoadInputHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history", "history.jsonl")
+
+	ih, err := loadInputHistory(path)
+	assert.NoError(t, err)
+	assert.Empty(t, ih.entries)
+
+	assert.NoError(t, ih.add("hello"))
+	assert.NoError(t, ih.add("line one\nline two"))
+
+	loaded, err := loadInputHistory(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hello", "line one\nline two"}, loaded.entries)
+	assert.Equal(t, 2, loaded.position)
+
+	// the file is trimmed to the newest entries
+	for i := 0; i < maxHistoryEntries-1; i++ {
+		loaded.entries = append(loaded.entries, "entry")
+	}
+	assert.NoError(t, loaded.rewrite())
+	loaded, err = loadInputHistory(path)
+	assert.NoError(t, err)
+	assert.Len(t, loaded.entries, maxHistoryEntries)
+	assert.Equal(t, "line one\nline two", loaded.entries[0])
+}
+
+func TestSaveDraft(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	assert.NoError(t, saveDraft("unfinished thought"))
+	assert.Equal(t, "unfinished thought", loadDraft())
+
+	assert.NoError(t, saveDraft(""))
+	assert.Equal(t, "", loadDraft())
+	draftFilePath, err := getDraftFilePath()
+	assert.NoError(t, err)
+	_, err = os.Stat(draftFilePath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestHandleHistoryKey(t *testing.T) {
+	typingView = tview.NewTextView()
+	previous := history
+	history = &inputHistory{entries: []string{"deploy staging", "hello", "deploy prod"}, position: 3}
+	t.Cleanup(func() { history = previous })
+
+	// the input field needs to be drawn once to know its width
+	screen := tcell.NewSimulationScreen("")
+	assert.NoError(t, screen.Init())
+	t.Cleanup(screen.Fini)
+	input := tview.NewInputField()
+	input.SetRect(0, 0, 80, 1)
+	input.Draw(screen)
+	input.SetText("typing")
+
+	assert.Nil(t, handleHistoryKey(input, tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)))
+	assert.Equal(t, "deploy prod", input.GetText())
+	assert.Nil(t, handleHistoryKey(input, tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)))
+	assert.Equal(t, "typing", input.GetText())
+
+	// Ctrl+R searches backwards, Ctrl+R again finds older matches
+	assert.Nil(t, handleHistoryKey(input, tcell.NewEventKey(tcell.KeyCtrlR, 0, tcell.ModCtrl)))
+	for _, r := range "dep" {
+		assert.Nil(t, handleHistoryKey(input, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)))
+	}
+	assert.Equal(t, "deploy prod", input.GetText())
+	assert.Equal(t, reverseSearch, input.GetLabel())
+	assert.Nil(t, handleHistoryKey(input, tcell.NewEventKey(tcell.KeyCtrlR, 0, tcell.ModCtrl)))
+	assert.Equal(t, "deploy staging", input.GetText())
+
+	// Escape restores the input
+	assert.Nil(t, handleHistoryKey(input, tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone)))
+	assert.Equal(t, "typing", input.GetText())
+	assert.Equal(t, message, input.GetLabel())
+
+	// other keys accept the match and are passed on
+	handleHistoryKey(input, tcell.NewEventKey(tcell.KeyCtrlR, 0, tcell.ModCtrl))
+	handleHistoryKey(input, tcell.NewEventKey(tcell.KeyRune, 'h', tcell.ModNone))
+	event := tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone)
+	assert.Equal(t, event, handleHistoryKey(input, event))
+	assert.Equal(t, "hello", input.GetText())
+	assert.False(t, historySearch.active)
+}
+
+func TestHandleHistoryKey_MovesCursor(t *testing.T) {
+	// the cursor is only moved once the input field was drawn
+	screen := tcell.NewSimulationScreen("")
+	assert.NoError(t, screen.Init())
+	input := tview.NewInputField().SetText("ac")
+	input.SetRect(0, 0, 20, 1)
+	input.Draw(screen)
+	typeRune := func(r rune) {
+		input.InputHandler()(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone), func(tview.Primitive) {})
+		input.Draw(screen)
+	}
+
+	assert.Nil(t, handleHistoryKey(input, tcell.NewEventKey(tcell.KeyCtrlB, 0, tcell.ModCtrl)))
+	typeRune('b')
+	assert.Nil(t, handleHistoryKey(input, tcell.NewEventKey(tcell.KeyCtrlF, 0, tcell.ModCtrl)))
+	typeRune('d')
+	assert.Equal(t, "abcd", input.GetText())
+}
@@ -0,0 +1,431 @@
+// main package
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	keysPageName      = "keys"
+	defaultKeymapName = "default"
+)
+
+// keyAction is an action that can be bound to keys.
+type keyAction string
+
+const (
+	actionScrollUp      keyAction = "scroll-up"
+	actionScrollDown    keyAction = "scroll-down"
+	actionPageUp        keyAction = "page-up"
+	actionPageDown      keyAction = "page-down"
+	actionScrollTop     keyAction = "scroll-top"
+	actionScrollBottom  keyAction = "scroll-bottom"
+	actionFocusInput    keyAction = "focus-input"
+	actionSelectMessage keyAction = "select-message"
+	actionToggleSidebar keyAction = "toggle-sidebar"
+	actionSearch        keyAction = "search"
+	actionJumpUnread    keyAction = "jump-unread"
+	actionShowKeys      keyAction = "show-keys"
+	actionQuit          keyAction = "quit"
+)
+
+// keyActions lists all actions with their description, in the order shown by /keys.
+var keyActions = []struct {
+	action keyAction
+	help   string
+}{
+	{actionScrollUp, "scroll the chat up by one line"},
+	{actionScrollDown, "scroll the chat down by one line"},
+	{actionPageUp, "scroll the chat up by one page"},
+	{actionPageDown, "scroll the chat down by one page"},
+	{actionScrollTop, "scroll to the oldest message"},
+	{actionScrollBottom, "scroll to the newest message"},
+	{actionFocusInput, "move the focus to the input field"},
+	{actionSelectMessage, "select messages in the chat view"},
+	{actionToggleSidebar, "show or hide the user list"},
+	{actionSearch, "search the loaded messages"},
+	{actionJumpUnread, "jump to the first unread message"},
+	{actionShowKeys, "show the key bindings"},
+	{actionQuit, "quit localterm"},
+}
+
+// keymapPresets are the built-in keymaps. Keys are written like "Ctrl+F", "Alt+u", "PgUp" or "j";
+// letters without Ctrl are case-sensitive. Bindings of editing keys like Ctrl+F only apply outside
+// of the input field, see isEditingKey; the search is also bound to F3 to be reachable from there.
+var keymapPresets = map[string]map[keyAction][]string{
+	"default": {
+		actionScrollUp:      {"Ctrl+Up"},
+		actionScrollDown:    {"Ctrl+Down"},
+		actionPageUp:        {"PgUp"},
+		actionPageDown:      {"PgDn"},
+		actionScrollTop:     {"Ctrl+Home"},
+		actionScrollBottom:  {"Ctrl+End"},
+		actionFocusInput:    {"Alt+i"},
+		actionSelectMessage: {"Alt+s"},
+		actionToggleSidebar: {"Alt+l"},
+		actionSearch:        {"Ctrl+F", "F3"},
+		actionJumpUnread:    {"Alt+u"},
+		actionShowKeys:      {"F1"},
+		actionQuit:          {"Ctrl+Q"},
+	},
+	"vim": {
+		actionScrollUp:      {"Ctrl+Y"},
+		actionScrollDown:    {"Ctrl+E"},
+		actionPageUp:        {"Ctrl+U", "PgUp"},
+		actionPageDown:      {"Ctrl+D", "PgDn"},
+		actionScrollTop:     {"Ctrl+Home"},
+		actionScrollBottom:  {"Ctrl+End"},
+		actionFocusInput:    {"i", "a"},
+		actionSelectMessage: {"Alt+s"},
+		actionToggleSidebar: {"Alt+l"},
+		actionSearch:        {"/", "Ctrl+F", "F3"},
+		actionJumpUnread:    {"Alt+u"},
+		actionShowKeys:      {"F1", "?"},
+		actionQuit:          {"Ctrl+Q"},
+	},
+	"emacs": {
+		actionScrollUp:      {"Alt+p"},
+		actionScrollDown:    {"Alt+n"},
+		actionPageUp:        {"Alt+v", "PgUp"},
+		actionPageDown:      {"Ctrl+V", "PgDn"},
+		actionScrollTop:     {"Alt+<"},
+		actionScrollBottom:  {"Alt+>"},
+		actionFocusInput:    {"Alt+i"},
+		actionSelectMessage: {"Alt+s"},
+		actionToggleSidebar: {"Alt+l"},
+		actionSearch:        {"Ctrl+S"},
+		actionJumpUnread:    {"Alt+u"},
+		actionShowKeys:      {"F1"},
+		actionQuit:          {"Ctrl+Q"},
+	},
+}
+
+// keymap maps the names of keys to actions.
+type keymap struct {
+	name     string
+	bindings map[keyAction][]string
+	actions  map[string]keyAction
+}
+
+// activeKeymap is the keymap in use, set before the UI starts
+var activeKeymap = newKeymap(defaultKeymapName, keymapPresets[defaultKeymapName])
+
+// keyNames maps the lower case names of the special keys to their names, e.g. "pgup" to "PgUp".
+var keyNames = func() map[string]string {
+	names := make(map[string]string)
+	for _, name := range tcell.KeyNames {
+		if !strings.HasPrefix(name, "Ctrl-") {
+			names[strings.ToLower(name)] = name
+		}
+	}
+	return names
+}()
+
+// newKeymap creates a keymap from the bindings, the key names are normalized.
+func newKeymap(name string, bindings map[keyAction][]string) *keymap {
+	km := &keymap{name: name, bindings: make(map[keyAction][]string), actions: make(map[string]keyAction)}
+	for action, keys := range bindings {
+		for _, key := range keys {
+			key = normalizeKeyName(key)
+			km.bindings[action] = append(km.bindings[action], key)
+			km.actions[key] = action
+		}
+	}
+	return km
+}
+
+// lookup returns the action bound to the key.
+func (km *keymap) lookup(key string) (keyAction, bool) {
+	action, ok := km.actions[key]
+	return action, ok
+}
+
+// keyFor returns the first key bound to the action, or an empty string if it is unbound.
+func (km *keymap) keyFor(action keyAction) string {
+	if keys := km.bindings[action]; len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// normalizeKeyName returns the canonical name of a key, e.g. "Ctrl+F" for "ctrl+f" and "PgUp"
+// for "pgup". Modifiers are ordered Ctrl, Alt, Shift.
+func normalizeKeyName(name string) string {
+	parts := strings.Split(name, "+")
+	// "Alt++" binds the plus key
+	if strings.HasSuffix(name, "++") {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	key := parts[len(parts)-1]
+
+	var ctrl, alt, shift bool
+	for _, modifier := range parts[:len(parts)-1] {
+		switch strings.ToLower(strings.TrimSpace(modifier)) {
+		case "ctrl", "control":
+			ctrl = true
+		case "alt", "meta":
+			alt = true
+		case "shift":
+			shift = true
+		}
+	}
+
+	if canonical, ok := keyNames[strings.ToLower(key)]; ok && len([]rune(key)) > 1 {
+		key = canonical
+	} else if ctrl && len([]rune(key)) == 1 {
+		key = strings.ToUpper(key)
+	}
+
+	var builder strings.Builder
+	if ctrl {
+		builder.WriteString("Ctrl+")
+	}
+	if alt {
+		builder.WriteString("Alt+")
+	}
+	if shift {
+		builder.WriteString("Shift+")
+	}
+	builder.WriteString(key)
+
+	return builder.String()
+}
+
+// formatKey returns the canonical name of the key of the event, see normalizeKeyName.
+func formatKey(event *tcell.EventKey) string {
+	modifiers := event.Modifiers()
+
+	var key string
+	switch {
+	case event.Key() == tcell.KeyRune:
+		key = string(event.Rune())
+		// runes are typed with shift, it is part of the rune
+		modifiers &^= tcell.ModShift
+		if modifiers&tcell.ModCtrl != 0 {
+			key = strings.ToUpper(key)
+		}
+	case event.Key() >= tcell.KeyCtrlA && event.Key() <= tcell.KeyCtrlZ &&
+		event.Key() != tcell.KeyBackspace && event.Key() != tcell.KeyTab && event.Key() != tcell.KeyEnter:
+		key = string(rune('A' + event.Key() - tcell.KeyCtrlA))
+		modifiers |= tcell.ModCtrl
+	default:
+		key = tcell.KeyNames[event.Key()]
+	}
+
+	var builder strings.Builder
+	if modifiers&tcell.ModCtrl != 0 {
+		builder.WriteString("Ctrl+")
+	}
+	if modifiers&tcell.ModAlt != 0 {
+		builder.WriteString("Alt+")
+	}
+	if modifiers&tcell.ModShift != 0 {
+		builder.WriteString("Shift+")
+	}
+	builder.WriteString(key)
+
+	return builder.String()
+}
+
+// isTypingKey reports whether the key edits the text of an input field, such keys are never
+// handled by the keymap while an input field has the focus.
+func isTypingKey(event *tcell.EventKey) bool {
+	if event.Modifiers()&(tcell.ModCtrl|tcell.ModAlt) != 0 {
+		return false
+	}
+
+	switch event.Key() {
+	case tcell.KeyRune, tcell.KeyEnter, tcell.KeyTab, tcell.KeyBacktab, tcell.KeyBackspace, tcell.KeyBackspace2,
+		tcell.KeyDelete, tcell.KeyLeft, tcell.KeyRight, tcell.KeyUp, tcell.KeyDown, tcell.KeyHome, tcell.KeyEnd,
+		tcell.KeyEscape:
+		return true
+	}
+	return false
+}
+
+// editingKeys are the readline-style keys of the input field, see handleHistoryKey.
+var editingKeys = map[string]bool{
+	"Ctrl+A": true, "Ctrl+E": true, "Ctrl+B": true, "Ctrl+F": true, "Alt+b": true, "Alt+f": true,
+	"Ctrl+D": true, "Ctrl+K": true, "Ctrl+U": true, "Ctrl+W": true, "Alt+d": true,
+	"Ctrl+P": true, "Ctrl+N": true, "Ctrl+R": true, "Ctrl+V": true,
+}
+
+// isEditingKey reports whether the key edits the text of the input field, bindings of these keys
+// are left out while the input field has the focus.
+func isEditingKey(event *tcell.EventKey) bool {
+	return editingKeys[formatKey(event)]
+}
+
+// getKeymapDir returns the directory custom keymaps are loaded from.
+func getKeymapDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".localchat", "keymaps"), nil
+}
+
+// customKeymap is the file format of custom keymaps. The bindings replace those of the preset
+// for the listed actions.
+type customKeymap struct {
+	Preset   string                 `json:"preset"`
+	Bindings map[keyAction][]string `json:"bindings"`
+}
+
+// loadKeymap returns the preset with the given name, or the custom keymap of the file <name>.json
+// in dir.
+func loadKeymap(name string, dir string) (*keymap, error) {
+	if name == "" {
+		name = defaultKeymapName
+	}
+	if preset, ok := keymapPresets[strings.ToLower(name)]; ok {
+		return newKeymap(strings.ToLower(name), preset), nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, filepath.Base(name)+".json"))
+	if err != nil {
+		return activeKeymap, fmt.Errorf("unknown keymap %s: %w", name, err)
+	}
+
+	var custom customKeymap
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return activeKeymap, fmt.Errorf("invalid keymap %s: %w", name, err)
+	}
+	if custom.Preset == "" {
+		custom.Preset = defaultKeymapName
+	}
+	preset, ok := keymapPresets[strings.ToLower(custom.Preset)]
+	if !ok {
+		return activeKeymap, fmt.Errorf("invalid keymap %s: unknown preset %s", name, custom.Preset)
+	}
+
+	bindings := make(map[keyAction][]string)
+	for action, keys := range preset {
+		bindings[action] = keys
+	}
+	for action, keys := range custom.Bindings {
+		if _, ok := preset[action]; !ok {
+			return activeKeymap, fmt.Errorf("invalid keymap %s: unknown action %s", name, action)
+		}
+		bindings[action] = keys
+	}
+
+	return newKeymap(name, bindings), nil
+}
+
+// loadConfiguredKeymap loads the keymap set with LOCALCHAT_KEYMAP, falling back to the default keymap.
+func loadConfiguredKeymap() *keymap {
+	keymapDir, err := getKeymapDir()
+	if err != nil {
+		slog.Warn("retrieving home path failed", "err", err)
+	}
+
+	loaded, err := loadKeymap(getEnvKeymap(), keymapDir)
+	if err != nil {
+		slog.Warn("loading keymap failed", "err", err)
+	}
+	return loaded
+}
+
+// handleKeymapKey runs the action bound to the key. Keys are only handled while no overlay is open,
+// and keys editing the text are left to the input field while it has the focus. It returns nil if
+// the event was handled. Ctrl+C always quits.
+func (app *app) handleKeymapKey(event *tcell.EventKey) *tcell.EventKey {
+	// quit instead of letting tview stop, so the connection stops handing payloads to the GUI first
+	if event.Key() == tcell.KeyCtrlC {
+		app.quit(errQuit)
+		return nil
+	}
+	if name, _ := pages.GetFrontPage(); name != mainPageName {
+		return event
+	}
+	if app.ui.GetFocus() != chatView && isTypingKey(event) {
+		return event
+	}
+	if app.ui.GetFocus() == inputField && isEditingKey(event) {
+		return event
+	}
+
+	action, ok := activeKeymap.lookup(formatKey(event))
+	if !ok {
+		return event
+	}
+	app.runKeyAction(action)
+	return nil
+}
+
+// runKeyAction runs the action.
+func (app *app) runKeyAction(action keyAction) {
+	_, _, _, height := chatView.GetInnerRect()
+	row, column := chatView.GetScrollOffset()
+
+	switch action {
+	case actionScrollUp:
+		scrollChatTo(max(0, row-1), column)
+	case actionScrollDown:
+		scrollChatTo(row+1, column)
+	case actionPageUp:
+		scrollChatTo(max(0, row-height), column)
+	case actionPageDown:
+		scrollChatTo(row+height, column)
+	case actionScrollTop:
+		scrollChatTo(0, 0)
+	case actionScrollBottom:
+		scrollChatToEnd()
+	case actionFocusInput:
+		app.leaveSelectionMode("")
+	case actionSelectMessage:
+		app.enterSelectionMode()
+	case actionToggleSidebar:
+		toggleSidebar()
+	case actionSearch:
+		app.showSearchBar("")
+	case actionJumpUnread:
+		app.jumpToFirstUnread()
+	case actionShowKeys:
+		app.showKeysView()
+	case actionQuit:
+		app.quit(errQuit)
+	}
+}
+
+// showKeysView opens an overlay listing the key bindings of the active keymap. It is closed with Escape.
+func (app *app) showKeysView() {
+	textView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
+	textView.SetBorder(true).SetTitle(fmt.Sprintf(" keys (%s) ", tview.Escape(activeKeymap.name)))
+
+	for _, entry := range keyActions {
+		keys := append([]string(nil), activeKeymap.bindings[entry.action]...)
+		sort.Strings(keys)
+		bound := colorTag(currentTheme.Muted) + "unbound[-]"
+		if len(keys) > 0 {
+			bound = colorTag(currentTheme.Accent) + tview.Escape(strings.Join(keys, ", ")) + "[-]"
+		}
+		fmt.Fprintf(textView, "%-16s %s\n    %s\n", entry.action, bound, tview.Escape(entry.help))
+	}
+
+	fmt.Fprintf(textView, "\n%sselected messages: %s[-]\n", colorTag(currentTheme.Muted), tview.Escape(selectionHint))
+	fmt.Fprint(textView, colorTag(currentTheme.Muted)+"Keys without Ctrl or Alt only apply while messages are selected. "+
+		"Set LOCALCHAT_KEYMAP to default, vim, emacs or the name of a file in ~/.localchat/keymaps, "+
+		"Escape closes this view[-]")
+
+	textView.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			app.hideOverlay(keysPageName)
+		}
+	})
+
+	app.showOverlay(keysPageName, textView)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeKeyName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Ctrl+F", want: "Ctrl+F"},
+		{name: "ctrl+f", want: "Ctrl+F"},
+		{name: "alt+ctrl+home", want: "Ctrl+Alt+Home"},
+		{name: "pgup", want: "PgUp"},
+		{name: "f1", want: "F1"},
+		{name: "Alt+u", want: "Alt+u"},
+		{name: "Alt+U", want: "Alt+U"},
+		{name: "j", want: "j"},
+		{name: "G", want: "G"},
+		{name: "Alt++", want: "Alt++"},
+		{name: "Shift+Tab", want: "Shift+Tab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizeKeyName(tt.name))
+		})
+	}
+}
+
+func TestFormatKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		event *tcell.EventKey
+		want  string
+	}{
+		{name: "ctrl letter", event: tcell.NewEventKey(tcell.KeyCtrlF, 0, tcell.ModCtrl), want: "Ctrl+F"},
+		{name: "ctrl letter without modifier", event: tcell.NewEventKey(tcell.KeyCtrlQ, 0, tcell.ModNone), want: "Ctrl+Q"},
+		{name: "alt rune", event: tcell.NewEventKey(tcell.KeyRune, 'u', tcell.ModAlt), want: "Alt+u"},
+		{name: "shifted rune", event: tcell.NewEventKey(tcell.KeyRune, 'G', tcell.ModShift), want: "G"},
+		{name: "special key", event: tcell.NewEventKey(tcell.KeyPgUp, 0, tcell.ModNone), want: "PgUp"},
+		{name: "ctrl special key", event: tcell.NewEventKey(tcell.KeyHome, 0, tcell.ModCtrl), want: "Ctrl+Home"},
+		{name: "function key", event: tcell.NewEventKey(tcell.KeyF1, 0, tcell.ModNone), want: "F1"},
+		{name: "tab", event: tcell.NewEventKey(tcell.KeyTab, 0, tcell.ModNone), want: "Tab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, formatKey(tt.event))
+		})
+	}
+}
+
+func TestIsTypingKey(t *testing.T) {
+	assert.True(t, isTypingKey(tcell.NewEventKey(tcell.KeyRune, 'a', tcell.ModNone)))
+	assert.True(t, isTypingKey(tcell.NewEventKey(tcell.KeyHome, 0, tcell.ModNone)))
+	assert.False(t, isTypingKey(tcell.NewEventKey(tcell.KeyRune, 'a', tcell.ModAlt)))
+	assert.False(t, isTypingKey(tcell.NewEventKey(tcell.KeyHome, 0, tcell.ModCtrl)))
+	assert.False(t, isTypingKey(tcell.NewEventKey(tcell.KeyPgUp, 0, tcell.ModNone)))
+}
+
+func TestIsEditingKey(t *testing.T) {
+	assert.True(t, isEditingKey(tcell.NewEventKey(tcell.KeyCtrlF, 0, tcell.ModCtrl)))
+	assert.True(t, isEditingKey(tcell.NewEventKey(tcell.KeyRune, 'b', tcell.ModAlt)))
+	assert.False(t, isEditingKey(tcell.NewEventKey(tcell.KeyF3, 0, tcell.ModNone)))
+	assert.False(t, isEditingKey(tcell.NewEventKey(tcell.KeyRune, 'u', tcell.ModAlt)))
+}
+
+func TestKeymapPresets(t *testing.T) {
+	for name, preset := range keymapPresets {
+		km := newKeymap(name, preset)
+
+		for _, entry := range keyActions {
+			assert.NotEmpty(t, km.bindings[entry.action], "%s: %s", name, entry.action)
+		}
+
+		// every key is bound to one action only
+		var count int
+		for _, keys := range km.bindings {
+			count += len(keys)
+		}
+		assert.Len(t, km.actions, count, name)
+	}
+}
+
+func TestLoadKeymap(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "mine.json"),
+		[]byte(`{"preset": "vim", "bindings": {"quit": ["ctrl+x"]}}`), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "unknown-action.json"),
+		[]byte(`{"bindings": {"fly": ["F2"]}}`), 0o600))
+
+	km, err := loadKeymap("", dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", km.name)
+
+	km, err = loadKeymap("Emacs", dir)
+	assert.NoError(t, err)
+	action, ok := km.lookup("Ctrl+S")
+	assert.True(t, ok)
+	assert.Equal(t, actionSearch, action)
+
+	km, err = loadKeymap("mine", dir)
+	assert.NoError(t, err)
+	action, ok = km.lookup("Ctrl+X")
+	assert.True(t, ok)
+	assert.Equal(t, actionQuit, action)
+	_, ok = km.lookup("Ctrl+Q")
+	assert.False(t, ok, "replaced binding")
+	// the other bindings are taken from the preset
+	action, _ = km.lookup("Ctrl+D")
+	assert.Equal(t, actionPageDown, action)
+	assert.Equal(t, "Ctrl+X", km.keyFor(actionQuit))
+
+	_, err = loadKeymap("unknown-action", dir)
+	assert.Error(t, err)
+
+	_, err = loadKeymap("missing", dir)
+	assert.Error(t, err)
+}
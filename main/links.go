@@ -0,0 +1,216 @@
+// main package
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	linksPageName = "links"
+	// maxLinks is the number of recent links listed by /links
+	maxLinks = 200
+)
+
+// urlRegex matches http and https URLs. Square brackets are excluded, as they would end a tview tag.
+var urlRegex = regexp.MustCompile(`https?://[^\s<>"'\x60\[\]]+`)
+
+// urlIndices returns the start and end of every URL in text. Trailing punctuation and unbalanced
+// closing parentheses, as in "(see https://example.com)." are not part of the URL.
+func urlIndices(text string) [][]int {
+	var indices [][]int
+	for _, loc := range urlRegex.FindAllStringIndex(text, -1) {
+		end := loc[1]
+		for end > loc[0] {
+			last := text[end-1]
+			if strings.IndexByte(".,;:!?", last) >= 0 ||
+				(last == ')' && strings.Count(text[loc[0]:end], "(") < strings.Count(text[loc[0]:end], ")")) {
+				end--
+				continue
+			}
+			break
+		}
+		indices = append(indices, []int{loc[0], end})
+	}
+	return indices
+}
+
+// findURLs returns all URLs in text.
+func findURLs(text string) []string {
+	var urls []string
+	for _, loc := range urlIndices(text) {
+		urls = append(urls, text[loc[0]:loc[1]])
+	}
+	return urls
+}
+
+// renderLink renders a link, as OSC 8 hyperlink if enabled. Terminals without hyperlink support
+// show the underlined text only.
+func renderLink(text string, link string) string {
+	if getEnvHyperlinks() && urlRegex.FindString(link) == link {
+		return "[:::" + link + "][::u]" + text + "[::-][:::-]"
+	}
+	return "[::u]" + text + "[::-]"
+}
+
+// linkifyURLs renders all URLs in the escaped text as links. renderText is applied to the text
+// between the URLs, e.g. to highlight mentions; it may be nil.
+func linkifyURLs(text string, renderText func(string) string) string {
+	if renderText == nil {
+		renderText = func(s string) string { return s }
+	}
+
+	var builder strings.Builder
+	last := 0
+	for _, loc := range urlIndices(text) {
+		builder.WriteString(renderText(text[last:loc[0]]))
+		builder.WriteString(renderLink(text[loc[0]:loc[1]], text[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	builder.WriteString(renderText(text[last:]))
+
+	return builder.String()
+}
+
+// linkEntry is a URL found in a message, listed by /links.
+type linkEntry struct {
+	url      string
+	clientID string
+	date     string
+	time     string
+}
+
+// getRecentLinks returns the URLs of the messages, newest first and without duplicates.
+func getRecentLinks(messages []messagePayload, limit int) []linkEntry {
+	seen := make(map[string]bool)
+	var links []linkEntry
+
+	for i := len(messages) - 1; i >= 0 && len(links) < limit; i-- {
+		decodedString, err := decodeBase64ToString(messages[i].MessageType.MessageContext)
+		if err != nil {
+			continue
+		}
+
+		urls := findURLs(decodedString)
+		for j := len(urls) - 1; j >= 0 && len(links) < limit; j-- {
+			if seen[urls[j]] {
+				continue
+			}
+			seen[urls[j]] = true
+			links = append(links, linkEntry{
+				url:      urls[j],
+				clientID: messages[i].ClientType.ClientDbID,
+				date:     getMessageDay(messages[i]),
+				time:     formatMessageClock(messages[i]),
+			})
+		}
+	}
+
+	return links
+}
+
+// isURLAllowed reports whether the URL may be opened. Only http and https URLs are opened, and if
+// an allowlist is configured only those of the listed hosts and their subdomains.
+func isURLAllowed(link string, allowlist []string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return false
+	}
+	if len(allowlist) == 0 {
+		return true
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range allowlist {
+		allowed = strings.ToLower(strings.TrimPrefix(allowed, "*."))
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// newOpenCommand returns the command opening the URL or file with the default application,
+// e.g. the browser.
+func newOpenCommand(target string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", target)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		return exec.Command("xdg-open", target)
+	}
+}
+
+// openURL opens the URL in the browser if it is allowed.
+func openURL(link string) error {
+	if !isURLAllowed(link, getEnvLinkAllowlist()) {
+		return errors.New("the link is not allowed, see LOCALCHAT_LINK_ALLOWLIST")
+	}
+
+	cmd := newOpenCommand(link)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+
+	return nil
+}
+
+// showLinksView opens an overlay listing the recent links of the archive, or of the loaded messages
+// if there is no archive. Links are filtered by the given term, Enter opens the selected link and
+// Escape closes the view.
+func (app *app) showLinksView(term string) {
+	messages := app.archive.getMessages()
+	if len(messages) == 0 {
+		messages = getMessagesFromCache()
+	}
+
+	var links []linkEntry
+	for _, link := range getRecentLinks(messages, maxLinks) {
+		if strings.Contains(strings.ToLower(link.url), strings.ToLower(term)) {
+			links = append(links, link)
+		}
+	}
+
+	list := tview.NewList().ShowSecondaryText(true)
+	list.SetBorder(true).SetTitle(fmt.Sprintf(" links (%d) - Enter opens, Escape closes ", len(links)))
+
+	if len(links) == 0 {
+		list.AddItem("no links found", "", 0, nil)
+	}
+	for _, link := range links {
+		list.AddItem(tview.Escape(link.url), fmt.Sprintf("%s %s - %s", link.date, link.time,
+			tview.Escape(getUsernameForID(link.clientID))), 0, nil)
+	}
+
+	list.SetSelectedFunc(func(index int, _ string, _ string, _ rune) {
+		if index >= len(links) {
+			return
+		}
+		if err := openURL(links[index].url); err != nil {
+			list.SetTitle(" " + err.Error() + " ")
+			return
+		}
+		app.hideOverlay(linksPageName)
+	})
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			app.hideOverlay(linksPageName)
+			return nil
+		}
+		return event
+	})
+
+	app.showOverlay(linksPageName, list)
+}
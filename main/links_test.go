@@ -0,0 +1,87 @@
+// main package
+package main
+
+import (
+	"testing"
+
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "no url", text: "hello world", want: nil},
+		{name: "single url", text: "see https://example.com/a?b=c#d", want: []string{"https://example.com/a?b=c#d"}},
+		{name: "trailing punctuation", text: "look at http://example.com.", want: []string{"http://example.com"}},
+		{name: "in parentheses", text: "(see https://example.com/x)", want: []string{"https://example.com/x"}},
+		{name: "balanced parentheses", text: "https://en.wikipedia.org/wiki/Go_(language)", want: []string{"https://en.wikipedia.org/wiki/Go_(language)"}},
+		{name: "multiple urls", text: "https://a.com and https://b.com", want: []string{"https://a.com", "https://b.com"}},
+		{name: "other scheme", text: "ftp://example.com", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, findURLs(tt.text))
+		})
+	}
+}
+
+func TestLinkifyURLs(t *testing.T) {
+	envVars.Hyperlinks = ""
+	got := linkifyURLs("@bob see https://example.com/@bob", func(text string) string {
+		return highlightMentions(text, []string{"bob"}, "alice")
+	})
+	assert.Equal(t, "[::b]@bob[::-] see [:::https://example.com/@bob][::u]https://example.com/@bob[::-][:::-]", got)
+	// the url tags are not printed
+	assert.Equal(t, len("@bob see https://example.com/@bob"), tview.TaggedStringWidth(got))
+
+	envVars.Hyperlinks = "off"
+	t.Cleanup(func() { envVars.Hyperlinks = "" })
+	assert.Equal(t, "see [::u]https://example.com[::-]", linkifyURLs("see https://example.com", nil))
+}
+
+func TestGetRecentLinks(t *testing.T) {
+	setTestClientList(t, client{ClientDbID: "1", ClientUsername: "alice"})
+	messages := []messagePayload{
+		newTestMessagePayload("1", "https://a.com and https://b.com"),
+		newTestMessagePayload("1", "no link"),
+		newTestMessagePayload("1", "again https://a.com"),
+	}
+
+	links := getRecentLinks(messages, 10)
+	var urls []string
+	for _, link := range links {
+		urls = append(urls, link.url)
+	}
+	assert.Equal(t, []string{"https://a.com", "https://b.com"}, urls)
+	assert.Equal(t, "1", links[0].clientID)
+
+	assert.Len(t, getRecentLinks(messages, 1), 1)
+}
+
+func TestIsURLAllowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		link      string
+		allowlist []string
+		want      bool
+	}{
+		{name: "no allowlist", link: "https://example.com", want: true},
+		{name: "other scheme", link: "file:///etc/passwd", want: false},
+		{name: "javascript", link: "javascript:alert(1)", want: false},
+		{name: "allowed host", link: "https://github.com/x", allowlist: []string{"github.com"}, want: true},
+		{name: "allowed subdomain", link: "https://gist.github.com/x", allowlist: []string{"*.github.com"}, want: true},
+		{name: "suffix is no subdomain", link: "https://evilgithub.com", allowlist: []string{"github.com"}, want: false},
+		{name: "not allowed", link: "https://example.com", allowlist: []string{"github.com"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isURLAllowed(tt.link, tt.allowlist))
+		})
+	}
+}
@@ -0,0 +1,313 @@
+// main package
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	logPageName = "log"
+	logFileName = "localterm.log"
+
+	// maxLogSize is the size a log file is rotated at
+	maxLogSize = 5 << 20
+	// maxLogFiles is the number of rotated log files kept besides the current one
+	maxLogFiles = 3
+	// maxRecentLogs is the number of warnings and errors kept for the /log pane
+	maxRecentLogs = 200
+)
+
+var (
+	// logFrames logs the raw websocket frames, enabled with --debug
+	logFrames bool
+	// recentLogs holds the latest warnings and errors shown by /log
+	recentLogs = &recentLogBuffer{}
+)
+
+// getLogDir returns the directory the log files are written to.
+func getLogDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".localchat", "logs"), nil
+}
+
+// parseLogLevel returns the level with the given name: debug, info (default), warn or error.
+func parseLogLevel(name string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// setupLogging writes the log to ~/.localchat/logs instead of the terminal, which is owned by the
+// GUI. The level is set with LOCALCHAT_LOG_LEVEL, debug logs everything including the raw
+// websocket frames. Warnings and errors are also kept for the /log pane. If the log file cannot be
+// opened, only those are kept.
+func setupLogging(debug bool) (io.Closer, error) {
+	level := parseLogLevel(getEnvLogLevel())
+	if debug {
+		level = slog.LevelDebug
+	}
+	logFrames = debug
+
+	writer, err := openLogFile()
+	if err != nil {
+		slog.SetDefault(slog.New(newRecentHandler(slog.NewTextHandler(io.Discard, nil), recentLogs)))
+		return nil, err
+	}
+
+	handler := slog.NewTextHandler(writer, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(newRecentHandler(handler, recentLogs)))
+	return writer, nil
+}
+
+// openLogFile opens the log file in the log directory.
+func openLogFile() (*rotatingWriter, error) {
+	logDir, err := getLogDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(logDir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return openRotatingWriter(filepath.Join(logDir, logFileName), maxLogSize, maxLogFiles)
+}
+
+// rotatingWriter writes to a file which is rotated once it exceeds maxSize: localterm.log becomes
+// localterm.log.1, localterm.log.1 becomes localterm.log.2 and so on, up to maxFiles.
+type rotatingWriter struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// openRotatingWriter opens the file at path for appending.
+func openRotatingWriter(path string, maxSize int64, maxFiles int) (*rotatingWriter, error) {
+	w := &rotatingWriter{path: path, maxSize: maxSize, maxFiles: max(maxFiles, 1)}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file, w.size = file, info.Size()
+	return nil
+}
+
+// Write writes p to the file, the file is rotated first if p does not fit anymore.
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	for i := w.maxFiles - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+
+	return w.open()
+}
+
+// Close closes the file.
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+type logEntry struct {
+	time    time.Time
+	level   slog.Level
+	message string
+}
+
+// recentLogBuffer keeps the latest maxRecentLogs entries.
+type recentLogBuffer struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (b *recentLogBuffer) add(entry logEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries = append(b.entries, entry)
+	if len(b.entries) > maxRecentLogs {
+		b.entries = slices.Clone(b.entries[len(b.entries)-maxRecentLogs:])
+	}
+}
+
+// getEntries returns a copy of the entries, the oldest first.
+func (b *recentLogBuffer) getEntries() []logEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return slices.Clone(b.entries)
+}
+
+// recentHandler passes the records to handler and keeps warnings and errors in recent, whatever
+// the level of handler is.
+type recentHandler struct {
+	handler slog.Handler
+	recent  *recentLogBuffer
+	attrs   []slog.Attr
+}
+
+func newRecentHandler(handler slog.Handler, recent *recentLogBuffer) *recentHandler {
+	return &recentHandler{handler: handler, recent: recent}
+}
+
+func (h *recentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.handler.Enabled(ctx, level)
+}
+
+func (h *recentHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn {
+		h.recent.add(logEntry{time: record.Time, level: record.Level, message: formatLogRecord(record, h.attrs)})
+	}
+	if !h.handler.Enabled(ctx, record.Level) {
+		return nil
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *recentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &recentHandler{
+		handler: h.handler.WithAttrs(attrs),
+		recent:  h.recent,
+		attrs:   append(slices.Clip(h.attrs), attrs...),
+	}
+}
+
+func (h *recentHandler) WithGroup(name string) slog.Handler {
+	return &recentHandler{handler: h.handler.WithGroup(name), recent: h.recent, attrs: h.attrs}
+}
+
+// formatLogRecord formats the message and the attributes of a record, e.g. "loading theme failed err=...".
+func formatLogRecord(record slog.Record, attrs []slog.Attr) string {
+	var message strings.Builder
+	message.WriteString(record.Message)
+
+	write := func(attr slog.Attr) bool {
+		fmt.Fprintf(&message, " %s=%v", attr.Key, attr.Value)
+		return true
+	}
+	for _, attr := range attrs {
+		write(attr)
+	}
+	record.Attrs(write)
+
+	return message.String()
+}
+
+// logFrame logs a raw websocket frame if enabled with --debug.
+func logFrame(direction string, frame []byte) {
+	if logFrames {
+		slog.Debug("websocket frame", "direction", direction, "frame", string(frame))
+	}
+}
+
+// showLogView opens an overlay listing the recent warnings and errors. It is closed with Escape.
+func (app *app) showLogView() {
+	textView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
+	textView.SetBorder(true).SetTitle(" log ")
+
+	entries := recentLogs.getEntries()
+	if len(entries) == 0 {
+		fmt.Fprintf(textView, "%sno warnings or errors[-]\n", colorTag(currentTheme.Muted))
+	}
+	for _, entry := range entries {
+		color := currentTheme.Accent
+		if entry.level >= slog.LevelError {
+			color = currentTheme.Error
+		}
+		fmt.Fprintf(textView, "%s%s %-5s[-] %s\n", colorTag(color), entry.time.Format("15:04:05"), entry.level,
+			tview.Escape(entry.message))
+	}
+
+	hint := "the full log is written to ~/.localchat/logs"
+	if logDir, err := getLogDir(); err == nil {
+		hint = "the full log is written to " + filepath.Join(logDir, logFileName)
+	}
+	fmt.Fprintf(textView, "\n%s%s, set LOCALCHAT_LOG_LEVEL or start with --debug for more, Escape closes this view[-]",
+		colorTag(currentTheme.Muted), tview.Escape(hint))
+	textView.ScrollToEnd()
+
+	textView.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			app.hideOverlay(logPageName)
+		}
+	})
+
+	app.showOverlay(logPageName, textView)
+}
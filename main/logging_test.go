@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		want slog.Level
+	}{
+		{name: "debug", want: slog.LevelDebug},
+		{name: " WARN ", want: slog.LevelWarn},
+		{name: "warning", want: slog.LevelWarn},
+		{name: "error", want: slog.LevelError},
+		{name: "", want: slog.LevelInfo},
+		{name: "verbose", want: slog.LevelInfo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseLogLevel(tt.name))
+		})
+	}
+}
+
+func TestRotatingWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), logFileName)
+	w, err := openRotatingWriter(path, 10, 2)
+	assert.NoError(t, err)
+	defer w.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := w.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+
+	current, _ := os.ReadFile(path)
+	first, _ := os.ReadFile(path + ".1")
+	second, _ := os.ReadFile(path + ".2")
+	assert.Equal(t, "fourth\n", string(current))
+	assert.Equal(t, "third\n", string(first))
+	assert.Equal(t, "second\n", string(second))
+	// only maxFiles rotated files are kept
+	assert.NoFileExists(t, path+".3")
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestRotatingWriter_AppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), logFileName)
+	assert.NoError(t, os.WriteFile(path, []byte("old\n"), 0o600))
+
+	w, err := openRotatingWriter(path, 100, 1)
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("new\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "old\nnew\n", string(content))
+
+	_, err = w.Write([]byte("closed\n"))
+	assert.Error(t, err)
+}
+
+func TestRecentHandler(t *testing.T) {
+	var out bytes.Buffer
+	recent := &recentLogBuffer{}
+	logger := slog.New(newRecentHandler(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelError}), recent))
+
+	logger.Info("connected")
+	logger.With("plugin", "echo").Warn("plugin is slow", "ms", 20)
+	logger.Error("writing failed", "err", "broken pipe")
+
+	entries := recent.getEntries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, slog.LevelWarn, entries[0].level)
+	assert.Equal(t, "plugin is slow plugin=echo ms=20", entries[0].message)
+	assert.Equal(t, "writing failed err=broken pipe", entries[1].message)
+
+	// the file only gets records of its level
+	assert.NotContains(t, out.String(), "connected")
+	assert.NotContains(t, out.String(), "plugin is slow")
+	assert.Contains(t, out.String(), "writing failed")
+}
+
+func TestRecentLogBuffer_Limit(t *testing.T) {
+	recent := &recentLogBuffer{}
+	for i := 0; i < maxRecentLogs+5; i++ {
+		recent.add(logEntry{message: fmt.Sprint(i)})
+	}
+
+	entries := recent.getEntries()
+	assert.Len(t, entries, maxRecentLogs)
+	assert.Equal(t, "5", entries[0].message)
+	assert.Equal(t, fmt.Sprint(maxRecentLogs+4), entries[len(entries)-1].message)
+}
@@ -2,21 +2,81 @@
 package main
 
 import (
-	"log"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	app := createApp()
+	// subcommands that do not start the GUI
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			os.Exit(runExportCommand(os.Args[2:]))
+		case "send":
+			os.Exit(runSendCommand(os.Args[2:]))
+		case "tail":
+			os.Exit(runTailCommand(os.Args[2:]))
+		}
+	}
+
+	os.Exit(run(os.Args[1:]))
+}
+
+// run starts the chat and returns the exit code once it ended. Quitting with /quit, the quit key,
+// a signal or a lost connection all cancel the context of the app, which stops the GUI.
+func run(args []string) int {
+	flags := flag.NewFlagSet("localterm", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	debug := flags.Bool("debug", false, "log everything including the raw websocket frames")
+	if err := flags.Parse(args); err != nil {
+		return exitCodeUsage
+	}
+
+	// tview owns the terminal, everything is logged to a file
+	logFile, err := setupLogging(*debug)
+	if err != nil {
+		slog.Warn("opening log file failed, only warnings are kept for /log", "err", err)
+	} else {
+		defer logFile.Close()
+	}
 
-	// Start the connection in a goroutine
+	app, err := createApp()
+	if err != nil {
+		slog.Error("starting localterm failed", "err", err)
+		fmt.Fprintln(os.Stderr, err)
+		return exitCodeError
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
 	go func() {
-		if err := connection(app); err != nil {
-			log.Fatal(err)
+		select {
+		case <-interrupt:
+			app.quit(errQuit)
+		case <-app.ctx.Done():
 		}
 	}()
 
+	connectionDone := make(chan struct{})
+	go func() {
+		defer close(connectionDone)
+		connection(app)
+	}()
+
+	go app.stopWhenQuitting()
+	go app.refreshRelativeTimes(relativeTimeInterval)
+
 	// Start the GUI in the main thread
 	if err := gui(app); err != nil {
-		log.Fatal(err)
+		app.quit(err)
 	}
+	// the GUI usually stops because the app quit, a GUI which stopped by itself quits as well
+	app.quit(errQuit)
+
+	return app.shutdown(connectionDone)
 }
@@ -0,0 +1,252 @@
+// main package
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/tview"
+)
+
+// codeLanguage describes how a fenced code block is highlighted.
+type codeLanguage struct {
+	keywords      map[string]bool
+	commentPrefix string
+}
+
+func newCodeLanguage(commentPrefix string, keywords string) codeLanguage {
+	language := codeLanguage{commentPrefix: commentPrefix, keywords: make(map[string]bool)}
+	for _, keyword := range strings.Fields(keywords) {
+		language.keywords[keyword] = true
+	}
+	return language
+}
+
+var codeLanguages = map[string]codeLanguage{
+	"go": newCodeLanguage("//", "break case chan const continue default defer else fallthrough for func go goto if "+
+		"import interface map package range return select struct switch type var nil true false"),
+	"python": newCodeLanguage("#", "and as assert async await break class continue def del elif else except "+
+		"finally for from global if import in is lambda nonlocal not or pass raise return try while with yield "+
+		"None True False"),
+	"javascript": newCodeLanguage("//", "async await break case catch class const continue default delete do "+
+		"else export extends finally for function if import in instanceof let new return switch this throw try "+
+		"typeof var void while yield null undefined true false"),
+	"shell": newCodeLanguage("#", "if then else elif fi for while until do done case esac function in return "+
+		"export local echo exit"),
+	"json": newCodeLanguage("", "true false null"),
+}
+
+var codeLanguageAliases = map[string]string{
+	"golang":     "go",
+	"py":         "python",
+	"js":         "javascript",
+	"ts":         "javascript",
+	"typescript": "javascript",
+	"sh":         "shell",
+	"bash":       "shell",
+	"zsh":        "shell",
+}
+
+// lookupCodeLanguage returns the highlighting rules for the language of a code fence, e.g. "go" or "py".
+func lookupCodeLanguage(name string) (codeLanguage, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := codeLanguageAliases[name]; ok {
+		name = alias
+	}
+	language, ok := codeLanguages[name]
+	return language, ok
+}
+
+// renderMarkdown renders a subset of Markdown into tview color tags: **bold**, *italic* and _italic_,
+// `inline code`, [links](url) and fenced code blocks with syntax highlighting. All text is escaped, so
+// square brackets in messages are never interpreted as tags. renderText is applied to the escaped plain
+// text outside of code, e.g. to highlight mentions; it may be nil.
+func renderMarkdown(text string, renderText func(string) string) string {
+	if renderText == nil {
+		renderText = func(s string) string { return s }
+	}
+
+	var lines []string
+	var language codeLanguage
+	var highlight, inCode bool
+
+	for _, line := range strings.Split(text, "\n") {
+		fence, isFence := strings.CutPrefix(strings.TrimSpace(line), "```")
+		switch {
+		case isFence && !inCode:
+			inCode = true
+			language, highlight = lookupCodeLanguage(strings.TrimSpace(fence))
+			lines = append(lines, colorTag(currentTheme.CodeBorder)+getMarkers().codeStart+tview.Escape(strings.TrimSpace(fence))+"[-]")
+		case isFence && inCode:
+			inCode = false
+			lines = append(lines, colorTag(currentTheme.CodeBorder)+getMarkers().codeEnd+"[-]")
+		case inCode && highlight:
+			lines = append(lines, colorTag(currentTheme.CodeBorder)+getMarkers().codeLine+"[-] "+highlightCode(line, language))
+		case inCode:
+			lines = append(lines, colorTag(currentTheme.CodeBorder)+getMarkers().codeLine+"[-] "+tview.Escape(line))
+		default:
+			lines = append(lines, renderInlineMarkdown(line, renderText))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+var (
+	markdownLinkRegex = regexp.MustCompile(`^\[([^\[\]]+)\]\((\S+?)\)`)
+	// tagLikeRegex matches text tview would interpret as a tag, it is kept in one piece to be escaped
+	tagLikeRegex = regexp.MustCompile(`^\[[a-zA-Z0-9_,;: \-\."#]+\[*\]`)
+)
+
+// renderInlineMarkdown renders the inline elements of a single line.
+func renderInlineMarkdown(line string, renderText func(string) string) string {
+	var builder strings.Builder
+	var plain strings.Builder
+
+	flush := func() {
+		if plain.Len() > 0 {
+			builder.WriteString(renderText(tview.Escape(plain.String())))
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(line); {
+		rest := line[i:]
+
+		switch {
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				flush()
+				builder.WriteString(colorTag(currentTheme.Code) + tview.Escape(rest[1:end+1]) + "[-]")
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(rest, "**"):
+			if end := strings.Index(rest[2:], "**"); end > 0 {
+				flush()
+				builder.WriteString("[::b]" + renderInlineMarkdown(rest[2:end+2], renderText) + "[::-]")
+				i += end + 4
+				continue
+			}
+		case rest[0] == '*' || rest[0] == '_':
+			if end, ok := findEmphasisEnd(line, i); ok {
+				flush()
+				builder.WriteString("[::i]" + renderInlineMarkdown(line[i+1:end], renderText) + "[::-]")
+				i = end + 1
+				continue
+			}
+		case rest[0] == '[':
+			if matches := markdownLinkRegex.FindStringSubmatch(rest); matches != nil {
+				flush()
+				builder.WriteString(renderMarkdownLink(matches[1], matches[2]))
+				i += len(matches[0])
+				continue
+			}
+			if match := tagLikeRegex.FindString(rest); match != "" {
+				plain.WriteString(match)
+				i += len(match)
+				continue
+			}
+		}
+
+		plain.WriteByte(line[i])
+		i++
+	}
+	flush()
+
+	return builder.String()
+}
+
+// findEmphasisEnd returns the index of the delimiter closing the *italic* or _italic_ text starting
+// at start. Delimiters must not be followed or preceded by spaces, and underscores within words
+// like snake_case are ignored.
+func findEmphasisEnd(line string, start int) (int, bool) {
+	delimiter := line[start]
+	if start+1 >= len(line) || line[start+1] == ' ' || line[start+1] == delimiter {
+		return 0, false
+	}
+	if delimiter == '_' && start > 0 && isWordByte(line[start-1]) {
+		return 0, false
+	}
+
+	for end := start + 2; end < len(line); end++ {
+		if line[end] != delimiter || line[end-1] == ' ' {
+			continue
+		}
+		if delimiter == '_' && end+1 < len(line) && isWordByte(line[end+1]) {
+			continue
+		}
+		return end, true
+	}
+	return 0, false
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= 0x80 || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
+}
+
+// renderMarkdownLink renders a link as underlined text followed by the url.
+func renderMarkdownLink(text string, url string) string {
+	return renderLink(tview.Escape(text), url) + " " + colorTag(currentTheme.Muted) + "(" + tview.Escape(url) + ")[-]"
+}
+
+// highlightCode colors keywords, strings, numbers and comments of a single line of code.
+// Strings and comments spanning multiple lines are not detected.
+func highlightCode(line string, language codeLanguage) string {
+	var builder strings.Builder
+	colored := func(color string, text string) {
+		builder.WriteString("[" + color + "]" + tview.Escape(text) + "[-]")
+	}
+
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		c := line[i]
+
+		if match := tagLikeRegex.FindString(rest); match != "" {
+			builder.WriteString(tview.Escape(match))
+			i += len(match)
+			continue
+		}
+
+		switch {
+		case language.commentPrefix != "" && strings.HasPrefix(rest, language.commentPrefix):
+			colored(currentTheme.CodeComment, rest)
+			return builder.String()
+		case c == '"' || c == '\'' || c == '`':
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(line))
+			colored(currentTheme.CodeString, line[i:end])
+			i = end
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(line) && (isWordByte(line[end]) || line[end] == '.') {
+				end++
+			}
+			colored(currentTheme.CodeNumber, line[i:end])
+			i = end
+		case isWordByte(c):
+			end := i
+			for end < len(line) && isWordByte(line[end]) {
+				end++
+			}
+			if word := line[i:end]; language.keywords[word] {
+				colored(currentTheme.CodeKeyword, word)
+			} else {
+				builder.WriteString(tview.Escape(word))
+			}
+			i = end
+		default:
+			builder.WriteByte(c)
+			i++
+		}
+	}
+
+	return builder.String()
+}
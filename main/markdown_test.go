@@ -0,0 +1,66 @@
+// main package
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "plain", text: "hello world", want: "hello world"},
+		{name: "color tag is escaped", text: "this is [red] not red", want: "this is [red[] not red"},
+		{name: "region tag is escaped", text: `["msg-1"] x`, want: `["msg-1"[] x`},
+		{name: "bold", text: "a **bold** b", want: "a [::b]bold[::-] b"},
+		{name: "italic", text: "*a* and _b_", want: "[::i]a[::-] and [::i]b[::-]"},
+		{name: "snake case", text: "snake_case_name", want: "snake_case_name"},
+		{name: "lone asterisk", text: "2 * 3 = 6", want: "2 * 3 = 6"},
+		{name: "inline code", text: "run `go test [x]`", want: "run [orange]go test [x[][-]"},
+		{name: "link", text: "see [docs](https://example.com)", want: "see [:::https://example.com][::u]docs[::-][:::-] [gray](https://example.com)[-]"},
+		{name: "tag inside emphasis", text: "[a_b_]", want: "[a_b_[]"},
+		{
+			name: "code block",
+			text: "look:\n```\nx := [red]\n```",
+			want: "look:\n[gray]╭ [-]\n[gray]│[-] x := [red[]\n[gray]╰[-]",
+		},
+		{
+			name: "highlighted code block",
+			text: "```go\nreturn \"a\", 42 // done\n```",
+			want: "[gray]╭ go[-]\n[gray]│[-] [yellow]return[-] [green]\"a\"[-], [aqua]42[-] [gray]// done[-]\n[gray]╰[-]",
+		},
+		{
+			name: "unclosed code block",
+			text: "```\n**not bold**",
+			want: "[gray]╭ [-]\n[gray]│[-] **not bold**",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, renderMarkdown(tt.text, nil))
+		})
+	}
+}
+
+func TestRenderMarkdown_Mentions(t *testing.T) {
+	got := renderMarkdown("hi @bob `@bob`", func(text string) string {
+		return highlightMentions(text, []string{"bob"}, "alice")
+	})
+	assert.Equal(t, "hi [::b]@bob[::-] [orange]@bob[-]", got)
+}
+
+func TestHighlightCode_Escaping(t *testing.T) {
+	language, ok := lookupCodeLanguage("py")
+	assert.True(t, ok)
+	assert.Equal(t, "items[0[] = [green]\"[x[]\"[-] [gray]# [red[][-]",
+		highlightCode(`items[0] = "[x]" # [red]`, language))
+	assert.Equal(t, "a[x y[]", highlightCode("a[x y]", language))
+
+	_, ok = lookupCodeLanguage("cobol")
+	assert.False(t, ok)
+}
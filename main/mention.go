@@ -0,0 +1,213 @@
+// main package
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const mentionsPageName = "mentions"
+
+var mentionRegex = regexp.MustCompile(`@([\p{L}\p{N}_\-.]*[\p{L}\p{N}_\-])`)
+
+// getThisClientUsername returns the username of this client as known by the server,
+// falling back to the LOCALCHAT_USERNAME environment variable.
+func getThisClientUsername() string {
+	if username := getThisClient().ClientUsername; username != "" {
+		return username
+	}
+	return getEnvUsername()
+}
+
+// getClientUsernames returns the usernames of all registered clients in alphabetical order.
+func getClientUsernames() []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	usernames := make([]string, 0, len(clientList.Clients))
+	for _, v := range clientList.Clients {
+		if v.ClientUsername != "" {
+			usernames = append(usernames, v.ClientUsername)
+		}
+	}
+	sort.Slice(usernames, func(i, j int) bool {
+		return strings.ToLower(usernames[i]) < strings.ToLower(usernames[j])
+	})
+
+	return usernames
+}
+
+// lookupUsername returns the registered username matching name case-insensitively.
+func lookupUsername(name string, usernames []string) (string, bool) {
+	for _, username := range usernames {
+		if strings.EqualFold(username, name) {
+			return username, true
+		}
+	}
+	return "", false
+}
+
+// mentionIndices returns the start and end of every @username in text, together with the start and end
+// of the username itself. An @ preceded by a letter or digit (like in mail addresses) does not start a mention.
+func mentionIndices(text string) [][]int {
+	var indices [][]int
+	for _, loc := range mentionRegex.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] > 0 {
+			previous, _ := utf8.DecodeLastRuneInString(text[:loc[0]])
+			if unicode.IsLetter(previous) || unicode.IsDigit(previous) {
+				continue
+			}
+		}
+		indices = append(indices, loc)
+	}
+	return indices
+}
+
+// findMentions returns all registered usernames mentioned with @username in the given text.
+// Every username is returned only once, in order of appearance.
+func findMentions(text string, usernames []string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+
+	for _, loc := range mentionIndices(text) {
+		username, ok := lookupUsername(text[loc[2]:loc[3]], usernames)
+		if !ok || seen[username] {
+			continue
+		}
+		seen[username] = true
+		mentions = append(mentions, username)
+	}
+
+	return mentions
+}
+
+// isMentioned reports whether the given username is mentioned in the text.
+func isMentioned(text string, username string) bool {
+	return len(findMentions(text, []string{username})) > 0
+}
+
+// highlightMentions wraps every mention of a registered user in color tags.
+// Mentions of ownUsername stand out with a background color, all others are printed bold.
+func highlightMentions(text string, usernames []string, ownUsername string) string {
+	var builder strings.Builder
+	last := 0
+
+	for _, loc := range mentionIndices(text) {
+		username, ok := lookupUsername(text[loc[2]:loc[3]], usernames)
+		if !ok {
+			continue
+		}
+
+		builder.WriteString(text[last:loc[0]])
+		if strings.EqualFold(username, ownUsername) {
+			builder.WriteString(colorTag(currentTheme.OwnMention) + text[loc[0]:loc[1]] + "[-:-]")
+		} else {
+			builder.WriteString("[::b]" + text[loc[0]:loc[1]] + "[::-]")
+		}
+		last = loc[1]
+	}
+	builder.WriteString(text[last:])
+
+	return builder.String()
+}
+
+// completeMention completes the @username prefix at the end of text.
+// A single match is completed including a trailing space, multiple matches are completed up to
+// their longest common prefix. It returns false if there is nothing to complete.
+func completeMention(text string, usernames []string) (string, bool) {
+	start := strings.LastIndexAny(text, " \t") + 1
+	word := text[start:]
+	if !strings.HasPrefix(word, "@") {
+		return text, false
+	}
+
+	prefix := strings.ToLower(word[1:])
+	var matches []string
+	for _, username := range usernames {
+		if strings.HasPrefix(strings.ToLower(username), prefix) {
+			matches = append(matches, username)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return text, false
+	case 1:
+		return text[:start] + "@" + matches[0] + " ", true
+	}
+
+	common := matches[0]
+	for _, match := range matches[1:] {
+		common = commonPrefixFold(common, match)
+	}
+	if len(common) <= len(prefix) {
+		return text, false
+	}
+
+	return text[:start] + "@" + common, true
+}
+
+// commonPrefixFold returns the longest case-insensitive common prefix of a and b, as spelled in a.
+func commonPrefixFold(a, b string) string {
+	ar, br := []rune(a), []rune(b)
+	i := 0
+	for i < len(ar) && i < len(br) && strings.EqualFold(string(ar[i]), string(br[i])) {
+		i++
+	}
+	return string(ar[:i])
+}
+
+// getMentionsOfThisClient returns the cache indices of all messages mentioning this client, in order.
+func getMentionsOfThisClient(messages []messagePayload) []int {
+	ownUsername := getThisClientUsername()
+
+	var indices []int
+	for index, payload := range messages {
+		decodedString, err := decodeBase64ToString(payload.MessageType.MessageContext)
+		if err != nil {
+			continue
+		}
+		if isMentioned(decodedString, ownUsername) {
+			indices = append(indices, index)
+		}
+	}
+
+	return indices
+}
+
+// showMentionsView opens an overlay listing all loaded messages that mention this client.
+// The overlay is closed with Escape.
+func (app *app) showMentionsView() {
+	messages := getMessagesFromCache()
+	indices := getMentionsOfThisClient(messages)
+	usernames := getClientUsernames()
+	ownUsername := getThisClientUsername()
+
+	textView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
+	textView.SetBorder(true).
+		SetTitle(fmt.Sprintf(" mentions of @%s (%d) ", ownUsername, len(indices)))
+
+	if len(indices) == 0 {
+		fmt.Fprint(textView, colorTag(currentTheme.Muted)+"nobody mentioned you yet[-]")
+	}
+	for _, index := range indices {
+		fmt.Fprintf(textView, "%s[%03d][-] %s\n", colorTag(currentTheme.Muted), index, formatMessageSummary(messages[index], usernames, ownUsername))
+	}
+
+	textView.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			app.hideOverlay(mentionsPageName)
+		}
+	})
+
+	app.showOverlay(mentionsPageName, textView)
+}
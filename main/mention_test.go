@@ -0,0 +1,82 @@
+// main package
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindMentions(t *testing.T) {
+	usernames := []string{"Alice", "bob", "Carl-Heinz"}
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "no mention", text: "hello world", want: nil},
+		{name: "single mention", text: "hi @alice!", want: []string{"Alice"}},
+		{name: "multiple mentions", text: "@bob and @Carl-Heinz.", want: []string{"bob", "Carl-Heinz"}},
+		{name: "duplicate mention", text: "@bob @BOB", want: []string{"bob"}},
+		{name: "unknown user", text: "@dave", want: nil},
+		{name: "mail address is not a mention", text: "mail@alice", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findMentions(tt.text, usernames); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findMentions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightMentions(t *testing.T) {
+	usernames := []string{"Alice", "bob"}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "own mention", text: "hi @alice", want: "hi [black:yellow]@alice[-:-]"},
+		{name: "other mention", text: "hi @bob", want: "hi [::b]@bob[::-]"},
+		{name: "unknown mention", text: "hi @dave", want: "hi @dave"},
+		{name: "mixed mentions", text: "@bob, @dave and @Alice", want: "[::b]@bob[::-], @dave and [black:yellow]@Alice[-:-]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightMentions(tt.text, usernames, "Alice"); got != tt.want {
+				t.Errorf("highlightMentions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteMention(t *testing.T) {
+	usernames := []string{"Alice", "Alina", "bob"}
+
+	tests := []struct {
+		name   string
+		text   string
+		want   string
+		wantOk bool
+	}{
+		{name: "unique match", text: "hey @b", want: "hey @bob ", wantOk: true},
+		{name: "common prefix", text: "@a", want: "@Ali", wantOk: true},
+		{name: "ambiguous without progress", text: "@ali", want: "@ali", wantOk: false},
+		{name: "no match", text: "@z", want: "@z", wantOk: false},
+		{name: "no at sign", text: "hello", want: "hello", wantOk: false},
+		{name: "empty", text: "", want: "", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := completeMention(tt.text, usernames)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("completeMention() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
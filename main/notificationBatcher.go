@@ -0,0 +1,130 @@
+// main package
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// notificationWindow is the time messages are collected before a single notification is sent.
+	notificationWindow = 2 * time.Second
+	// notificationSenderInterval is the minimum time between two notifications for the same sender.
+	notificationSenderInterval = 10 * time.Second
+)
+
+// notificationBatcher coalesces messages arriving within a short window into one notification
+// and rate limits notifications per sender.
+type notificationBatcher struct {
+	now          func() time.Time
+	lastNotified map[string]time.Time
+	timer        *time.Timer
+	notifier     notifier
+	pending      []messagePayload
+	window       time.Duration
+	interval     time.Duration
+	mu           sync.Mutex
+}
+
+func newNotificationBatcher(notifier notifier) *notificationBatcher {
+	return &notificationBatcher{
+		notifier:     notifier,
+		window:       notificationWindow,
+		interval:     notificationSenderInterval,
+		now:          time.Now,
+		lastNotified: make(map[string]time.Time),
+	}
+}
+
+// add queues a message for the next notification. Messages of senders that were notified about
+// less than the sender interval ago are dropped.
+func (nb *notificationBatcher) add(payload messagePayload) {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+
+	sender := payload.ClientType.ClientDbID
+	if last, exists := nb.lastNotified[sender]; exists && nb.now().Sub(last) < nb.interval {
+		return
+	}
+
+	nb.pending = append(nb.pending, payload)
+
+	if nb.timer == nil {
+		nb.timer = time.AfterFunc(nb.window, nb.flush)
+	}
+}
+
+// flush sends one notification for all pending messages.
+func (nb *notificationBatcher) flush() {
+	nb.mu.Lock()
+	pending := nb.pending
+	nb.pending = nil
+	if nb.timer != nil {
+		nb.timer.Stop()
+		nb.timer = nil
+	}
+
+	now := nb.now()
+	for _, payload := range pending {
+		nb.lastNotified[payload.ClientType.ClientDbID] = now
+	}
+	nb.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	title, message, icon := summarizeNotification(pending)
+	if err := nb.notifier.Notify(title, message, icon); err != nil {
+		slog.Warn("sending desktop notification failed", "err", err)
+	}
+}
+
+// summarizeNotification returns title, message and icon of the notification for the given messages.
+// A single message is shown as is, multiple messages are summarized, e.g. "5 new messages from Alice and Bob".
+func summarizeNotification(payloads []messagePayload) (title, message, icon string) {
+	if len(payloads) == 1 {
+		payload := payloads[0]
+		decodedString, err := decodeBase64ToString(payload.MessageType.MessageContext)
+		if err != nil {
+			slog.Error("decoding base64 to string failed", "err", err)
+		}
+		title = "message from "
+		if isReplyToOwnMessage(getMessagesFromCache(), payload) {
+			title = "reply from "
+		}
+		return title + getUsernameForID(payload.ClientType.ClientDbID), decodedString,
+			getNotificationIcon(payload.ClientType.ClientDbID)
+	}
+
+	var senders []string
+	seen := make(map[string]bool)
+	for _, payload := range payloads {
+		if seen[payload.ClientType.ClientDbID] {
+			continue
+		}
+		seen[payload.ClientType.ClientDbID] = true
+		senders = append(senders, getUsernameForID(payload.ClientType.ClientDbID))
+	}
+
+	if len(senders) == 1 {
+		icon = getNotificationIcon(payloads[0].ClientType.ClientDbID)
+	}
+
+	return "localchat", fmt.Sprintf("%d new messages from %s", len(payloads), joinUsernames(senders)), icon
+}
+
+// joinUsernames joins the usernames like "Alice", "Alice and Bob" or "Alice, Bob and Carl".
+func joinUsernames(usernames []string) string {
+	switch len(usernames) {
+	case 0:
+		return ""
+	case 1:
+		return usernames[0]
+	default:
+		return strings.Join(usernames[:len(usernames)-1], ", ") + " and " + usernames[len(usernames)-1]
+	}
+}
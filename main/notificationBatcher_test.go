@@ -0,0 +1,109 @@
+// main package
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMessagePayload(clientID string, text string) messagePayload {
+	return messagePayload{
+		PayloadType: messageTypeConst,
+		MessageType: messageType{
+			MessageDbID:    GenerateRandomID(),
+			MessageContext: base64.StdEncoding.EncodeToString([]byte(text)),
+		},
+		ClientType: clientType{ClientDbID: clientID},
+	}
+}
+
+func setTestClientList(t *testing.T, clients ...client) {
+	t.Helper()
+	setClientList(&clientListStruct{Clients: clients})
+	t.Cleanup(func() {
+		setClientList(&clientListStruct{})
+	})
+}
+
+func TestNotificationBatcher_Coalesce(t *testing.T) {
+	setTestClientList(t,
+		client{ClientDbID: "1", ClientUsername: "Alice"},
+		client{ClientDbID: "2", ClientUsername: "Bob"})
+
+	mockNotifier := &MockNotifier{}
+	nb := newNotificationBatcher(mockNotifier)
+	nb.window = time.Hour
+
+	nb.add(newTestMessagePayload("1", "one"))
+	nb.add(newTestMessagePayload("2", "two"))
+	nb.add(newTestMessagePayload("1", "three"))
+	nb.flush()
+
+	assert.Len(t, mockNotifier.Calls, 1)
+	assert.Equal(t, "3 new messages from Alice and Bob", mockNotifier.Calls[0].Message)
+
+	// nothing pending, nothing to send
+	nb.flush()
+	assert.Len(t, mockNotifier.Calls, 1)
+}
+
+func TestNotificationBatcher_SingleMessage(t *testing.T) {
+	setTestClientList(t, client{ClientDbID: "1", ClientUsername: "Alice"})
+
+	mockNotifier := &MockNotifier{}
+	nb := newNotificationBatcher(mockNotifier)
+	nb.window = time.Hour
+
+	nb.add(newTestMessagePayload("1", "hello"))
+	nb.flush()
+
+	assert.Len(t, mockNotifier.Calls, 1)
+	assert.Equal(t, "message from Alice", mockNotifier.Calls[0].Title)
+	assert.Equal(t, "hello", mockNotifier.Calls[0].Message)
+}
+
+func TestNotificationBatcher_RateLimit(t *testing.T) {
+	mockNotifier := &MockNotifier{}
+	nb := newNotificationBatcher(mockNotifier)
+	nb.window = time.Hour
+
+	now := time.Now()
+	nb.now = func() time.Time { return now }
+
+	nb.add(newTestMessagePayload("1", "first"))
+	nb.flush()
+
+	// within the sender interval
+	now = now.Add(notificationSenderInterval / 2)
+	nb.add(newTestMessagePayload("1", "second"))
+	nb.flush()
+	assert.Len(t, mockNotifier.Calls, 1)
+
+	// after the sender interval
+	now = now.Add(notificationSenderInterval)
+	nb.add(newTestMessagePayload("1", "third"))
+	nb.flush()
+	assert.Len(t, mockNotifier.Calls, 2)
+}
+
+func TestJoinUsernames(t *testing.T) {
+	tests := []struct {
+		name      string
+		want      string
+		usernames []string
+	}{
+		{name: "none", usernames: nil, want: ""},
+		{name: "one", usernames: []string{"Alice"}, want: "Alice"},
+		{name: "two", usernames: []string{"Alice", "Bob"}, want: "Alice and Bob"},
+		{name: "three", usernames: []string{"Alice", "Bob", "Carl"}, want: "Alice, Bob and Carl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, joinUsernames(tt.usernames))
+		})
+	}
+}
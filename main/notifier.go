@@ -0,0 +1,233 @@
+// main package
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+const (
+	beeepNotifierName   = "beeep"
+	bellNotifierName    = "bell"
+	osc9NotifierName    = "osc9"
+	osc777NotifierName  = "osc777"
+	commandNotifierName = "exec"
+	noopNotifierName    = "none"
+
+	commandNotifierTimeout = 10 * time.Second
+)
+
+// newNotifier returns the notifier with the given name.
+// The command is only used by the exec notifier, which requires it to be set.
+func newNotifier(name string, command string) (notifier, error) {
+	switch name {
+	case beeepNotifierName:
+		return &beeepNotifier{}, nil
+	case bellNotifierName, osc9NotifierName, osc777NotifierName:
+		return &terminalNotifier{mode: name, out: os.Stdout}, nil
+	case commandNotifierName:
+		if command == "" {
+			return nil, fmt.Errorf("notifier %q needs LOCALCHAT_NOTIFIER_COMMAND to be set", name)
+		}
+		return &commandNotifier{command: command}, nil
+	case noopNotifierName:
+		return &noopNotifier{}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", name)
+	}
+}
+
+// terminalNotifier notifies through escape sequences written to the terminal, which also works
+// in remote SSH sessions. Depending on the mode it rings the bell, or emits an OSC 9 (iTerm2, kitty,
+// Windows Terminal) or OSC 777 (urxvt, foot, VTE based terminals) notification.
+type terminalNotifier struct {
+	out  io.Writer
+	mode string
+}
+
+// Notify writes the notification escape sequence for the configured mode. Icons are not supported.
+func (tn *terminalNotifier) Notify(title, message, _ string) error {
+	title = sanitizeTerminalText(title)
+	message = sanitizeTerminalText(message)
+
+	var sequence string
+	switch tn.mode {
+	case osc9NotifierName:
+		sequence = fmt.Sprintf("\x1b]9;%s: %s\x07", title, message)
+	case osc777NotifierName:
+		sequence = fmt.Sprintf("\x1b]777;notify;%s;%s\x07", strings.ReplaceAll(title, ";", ","), message)
+	default:
+		sequence = "\a"
+	}
+
+	_, err := io.WriteString(tn.out, sequence)
+	return err
+}
+
+// uiWriterQueueSize is the number of writes buffered for the UI goroutine before writes are dropped.
+const uiWriterQueueSize = 16
+
+// uiWriter hands writes to the terminal over to the UI goroutine, so escape sequences are not
+// written in the middle of a screen update of tview. Writes are queued and passed on by a single
+// goroutine, they do not wait for the UI goroutine, which makes them safe from timers as well as
+// from the UI goroutine itself. Once the context is done, or if the queue is full, writes are
+// dropped; errors are logged.
+type uiWriter struct {
+	ui     *tview.Application
+	out    io.Writer
+	ctx    context.Context
+	writes chan []byte
+}
+
+// newUIWriter returns a uiWriter writing to out until ctx is done.
+func newUIWriter(ctx context.Context, ui *tview.Application, out io.Writer) *uiWriter {
+	w := &uiWriter{ui: ui, out: out, ctx: ctx, writes: make(chan []byte, uiWriterQueueSize)}
+	go w.run()
+	return w
+}
+
+func (w *uiWriter) Write(p []byte) (int, error) {
+	if w.ctx.Err() != nil {
+		return len(p), nil
+	}
+
+	select {
+	case w.writes <- bytes.Clone(p):
+	default:
+		slog.Warn("dropping a write to the terminal, the UI is busy")
+	}
+	return len(p), nil
+}
+
+// run passes the queued writes to the UI goroutine until the context is done.
+func (w *uiWriter) run() {
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case data := <-w.writes:
+			w.ui.QueueUpdate(func() {
+				if _, err := w.out.Write(data); err != nil {
+					slog.Warn("writing to the terminal failed", "err", err)
+				}
+			})
+		}
+	}
+}
+
+// sanitizeTerminalText removes control characters so that the text cannot terminate the escape sequence.
+func sanitizeTerminalText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return ' '
+		}
+		if r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// commandNotifier runs a user supplied command for every notification.
+// The notification is passed as JSON on stdin.
+type commandNotifier struct {
+	command string
+}
+
+type commandNotification struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	Icon    string `json:"icon,omitempty"`
+}
+
+// Notify runs the command and writes the notification as JSON to its stdin.
+func (cn *commandNotifier) Notify(title, message, icon string) error {
+	input, err := json.Marshal(commandNotification{Title: title, Message: message, Icon: icon})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandNotifierTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", cn.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", cn.command)
+	}
+	cmd.Stdin = strings.NewReader(string(input))
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notifier command failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// noopNotifier discards all notifications.
+type noopNotifier struct{}
+
+// Notify does nothing.
+func (nn *noopNotifier) Notify(_, _, _ string) error {
+	return nil
+}
+
+// getNotificationIcon returns the path of an image file for the profile image of the given client.
+// The profile image is a base64 encoded image, optionally as data URI, which is written to
+// ~/.localchat/icons/. Profile images are set by other clients, so anything else, like a path to a
+// local file, is ignored. It returns an empty string if the client has no usable profile image.
+func getNotificationIcon(clientID string) string {
+	profileImage := getClientProfileImage(clientID)
+	iconName := filepath.Base(clientID)
+	if profileImage == "" || iconName == "." || iconName == ".." || iconName == string(filepath.Separator) {
+		return ""
+	}
+
+	// strip data URI prefix, e.g. "data:image/png;base64,"
+	if strings.HasPrefix(profileImage, "data:") {
+		_, data, found := strings.Cut(profileImage, ",")
+		if !found {
+			return ""
+		}
+		profileImage = data
+	}
+
+	image, err := base64.StdEncoding.DecodeString(profileImage)
+	if err != nil || !strings.HasPrefix(http.DetectContentType(image), "image/") {
+		return ""
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	iconPath := filepath.Join(homeDir, ".localchat", "icons", iconName)
+	if err := os.MkdirAll(filepath.Dir(iconPath), 0o700); err != nil {
+		return ""
+	}
+
+	// only rewrite the icon if the profile image changed
+	if existing, err := os.ReadFile(iconPath); err == nil && bytes.Equal(existing, image) {
+		return iconPath
+	}
+	if err := os.WriteFile(iconPath, image, 0o600); err != nil {
+		return ""
+	}
+
+	return iconPath
+}
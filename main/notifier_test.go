@@ -0,0 +1,156 @@
+// main package
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNotifier(t *testing.T) {
+	tests := []struct {
+		want    notifier
+		name    string
+		command string
+		wantErr bool
+	}{
+		{name: beeepNotifierName, want: &beeepNotifier{}},
+		{name: osc9NotifierName, want: &terminalNotifier{mode: osc9NotifierName, out: os.Stdout}},
+		{name: commandNotifierName, command: "cat", want: &commandNotifier{command: "cat"}},
+		{name: commandNotifierName, wantErr: true},
+		{name: noopNotifierName, want: &noopNotifier{}},
+		{name: "unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newNotifier(tt.name, tt.command)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTerminalNotifier_Notify(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		title   string
+		message string
+		want    string
+	}{
+		{name: "bell", mode: bellNotifierName, title: "t", message: "m", want: "\a"},
+		{name: "osc9", mode: osc9NotifierName, title: "t", message: "m", want: "\x1b]9;t: m\x07"},
+		{name: "osc777", mode: osc777NotifierName, title: "a;b", message: "m", want: "\x1b]777;notify;a,b;m\x07"},
+		{name: "control characters", mode: osc9NotifierName, title: "t", message: "a\x07\x1b\nb", want: "\x1b]9;t: a b\x07"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			tn := &terminalNotifier{mode: tt.mode, out: &out}
+			assert.NoError(t, tn.Notify(tt.title, tt.message, ""))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestCommandNotifier_Notify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "notification.json")
+	cn := &commandNotifier{command: "cat > " + outputPath}
+
+	assert.NoError(t, cn.Notify("title", "message", "icon.png"))
+
+	output, err := os.ReadFile(outputPath)
+	assert.NoError(t, err)
+
+	var got commandNotification
+	assert.NoError(t, json.Unmarshal(output, &got))
+	assert.Equal(t, commandNotification{Title: "title", Message: "message", Icon: "icon.png"}, got)
+
+	failing := &commandNotifier{command: "exit 1"}
+	assert.Error(t, failing.Notify("title", "message", ""))
+}
+
+func TestGetNotificationIcon(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	localFile := filepath.Join(t.TempDir(), "secret.png")
+	assert.NoError(t, os.WriteFile(localFile, []byte("secret"), 0o600))
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	encoded := base64.StdEncoding.EncodeToString(png)
+	setTestClientList(t,
+		client{ClientDbID: "a", ClientProfileImage: encoded},
+		client{ClientDbID: "b", ClientProfileImage: "data:image/png;base64," + encoded},
+		client{ClientDbID: "c", ClientProfileImage: localFile},
+		client{ClientDbID: "d", ClientProfileImage: base64.StdEncoding.EncodeToString([]byte("#!/bin/sh\necho hi\n"))},
+		client{ClientDbID: "..", ClientProfileImage: encoded})
+
+	for _, id := range []string{"a", "b"} {
+		icon := getNotificationIcon(id)
+		assert.Equal(t, filepath.Join(home, ".localchat", "icons", id), icon)
+		content, err := os.ReadFile(icon)
+		assert.NoError(t, err)
+		assert.Equal(t, png, content)
+	}
+
+	// local files and anything but images are ignored
+	assert.Empty(t, getNotificationIcon("c"))
+	assert.Empty(t, getNotificationIcon("d"))
+	assert.Empty(t, getNotificationIcon(".."))
+	assert.Empty(t, getNotificationIcon("unknown"))
+}
+
+func TestUIWriter(t *testing.T) {
+	screen := tcell.NewSimulationScreen("UTF-8")
+	ui := tview.NewApplication().SetScreen(screen).SetRoot(tview.NewBox(), true)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ui.Run()
+	}()
+	t.Cleanup(func() {
+		ui.Stop()
+		<-stopped
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out bytes.Buffer
+	w := newUIWriter(ctx, ui, &out)
+
+	_, err := w.Write([]byte("\a"))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		var written string
+		ui.QueueUpdate(func() { written = out.String() })
+		return written == "\a"
+	}, time.Second, 10*time.Millisecond)
+
+	// writes after the UI stopped are dropped instead of waiting for it
+	cancel()
+	n, err := w.Write([]byte("\a"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, w.writes)
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/gorilla/websocket"
@@ -9,9 +10,14 @@ import (
 )
 
 type app struct {
-	ui       *tview.Application
-	notifier notifier
-	conn     *websocket.Conn
+	ui            *tview.Application
+	notifier      notifier
+	notifications *notificationBatcher
+	archive       *messageArchive
+	conn          *websocket.Conn
+	// ctx is cancelled when localterm quits, its cause tells why
+	ctx    context.Context
+	cancel context.CancelCauseFunc
 }
 
 type messageListPayload struct {
@@ -46,6 +52,10 @@ type messageType struct {
 	MessageDate    string `json:"messageDate"`
 	Deleted        bool   `json:"deleted"`
 	Edited         bool   `json:"edited"`
+	// Timestamp is the RFC 3339 UTC time the message was sent, older clients only send MessageDate and MessageTime
+	Timestamp string `json:"timestamp,omitempty"`
+	// ParentMessageDbID is the MessageDbID of the message a reply belongs to, empty for other messages
+	ParentMessageDbID string `json:"parentMessageDbId,omitempty"`
 }
 
 type clientType struct {
@@ -62,12 +72,14 @@ type quoteType struct {
 	QuoteMessageContext string `json:"quoteMessageContext"`
 	QuoteTime           string `json:"quoteTime"`
 	QuoteDate           string `json:"quoteDate"`
+	QuoteTimestamp      string `json:"quoteTimestamp,omitempty"`
 }
 
 type reactionType struct {
 	ReactionMessageID string `json:"reactionMessageId"`
 	ReactionContext   string `json:"reactionContext"`
 	ReactionClientID  string `json:"reactionClientId"`
+	ReactionRemoved   bool   `json:"reactionRemoved,omitempty"`
 }
 
 type reactionPayload struct {
@@ -76,6 +88,8 @@ type reactionPayload struct {
 	ReactionContext   string      `json:"reactionContext"`
 	ReactionClientID  string      `json:"reactionClientId"`
 	PayloadType       payloadType `json:"payloadType"`
+	// ReactionRemoved removes the earlier reaction of the client with the same content
+	ReactionRemoved bool `json:"reactionRemoved,omitempty"`
 }
 
 type typingPayload struct {
@@ -84,6 +98,21 @@ type typingPayload struct {
 	IsTyping    bool        `json:"isTyping"`
 }
 
+// messageAckPayload is sent by the server once it stored a message of this client
+type messageAckPayload struct {
+	MessageDbID string `json:"messageDbId"`
+	// Error is set if the server could not store the message
+	Error       string      `json:"error,omitempty"`
+	PayloadType payloadType `json:"payloadType"`
+}
+
+// readReceiptPayload tells the other clients the last message a client has read
+type readReceiptPayload struct {
+	ClientDbID  string      `json:"clientDbId"`
+	MessageDbID string      `json:"messageDbId"`
+	PayloadType payloadType `json:"payloadType"`
+}
+
 type authenticationPayload struct {
 	ClientUsername string      `json:"clientUsername"`
 	ClientDbID     string      `json:"clientDbId"`
@@ -0,0 +1,397 @@
+// main package
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pluginEvent is an inbound payload as passed to plugins. Messages are decoded with usernames resolved.
+type pluginEvent struct {
+	Message     *exportedMessage `json:"message,omitempty"`
+	ClientID    string           `json:"clientId,omitempty"`
+	Payload     json.RawMessage  `json:"payload"`
+	PayloadType payloadType      `json:"payloadType"`
+}
+
+// pluginHost is what plugins can do in the chat. The methods are called from the goroutines of
+// the plugins, the app queues them on the UI goroutine.
+type pluginHost interface {
+	// sendMessage sends a plain message as this client.
+	sendMessage(text string)
+	// sendReaction reacts to the message with the given MessageDbID.
+	sendReaction(messageDbID string, reaction string)
+	// writeSystemLine prints a local-only line that is never sent to the server.
+	writeSystemLine(text string)
+}
+
+// plugin is implemented by bots and automations. Plugins receive every inbound payload and
+// can provide slash commands for the input field.
+type plugin interface {
+	name() string
+	// commands returns the slash commands handled by the plugin, including the leading slash.
+	commands() []string
+	handleEvent(event pluginEvent, host pluginHost)
+	handleCommand(command string, argument string, host pluginHost)
+}
+
+var (
+	pluginMutex sync.Mutex
+	plugins     []plugin
+)
+
+// registerPlugin adds a plugin to the running client.
+func registerPlugin(p plugin) {
+	pluginMutex.Lock()
+	defer pluginMutex.Unlock()
+
+	plugins = append(plugins, p)
+}
+
+func getPlugins() []plugin {
+	pluginMutex.Lock()
+	defer pluginMutex.Unlock()
+
+	return append([]plugin(nil), plugins...)
+}
+
+// newPluginEvent wraps the raw payload, decoding it if it is a message.
+func newPluginEvent(payloadType payloadType, payload []byte) pluginEvent {
+	event := pluginEvent{
+		PayloadType: payloadType,
+		Payload:     json.RawMessage(payload),
+	}
+
+	if payloadType == messageTypeConst {
+		if messagePayload, err := unmarshallPayloadToMessagePayload(payload); err == nil {
+			message := newExportedMessage(messagePayload)
+			event.Message = &message
+			event.ClientID = messagePayload.ClientType.ClientDbID
+		}
+	}
+
+	return event
+}
+
+// dispatchToPlugins passes the inbound payload to all plugins.
+func dispatchToPlugins(payloadType payloadType, payload []byte, host pluginHost) {
+	registered := getPlugins()
+	if len(registered) == 0 {
+		return
+	}
+
+	event := newPluginEvent(payloadType, payload)
+	for _, p := range registered {
+		p.handleEvent(event, host)
+	}
+}
+
+// findPluginCommand returns the plugin providing the slash command.
+func findPluginCommand(command string) (plugin, bool) {
+	for _, p := range getPlugins() {
+		for _, c := range p.commands() {
+			if c == command {
+				return p, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// processPluginMessage is a line of the external plugin protocol. Inbound payloads are written to the
+// plugin's stdin as {"type":"event","event":{...}} and {"type":"command","command":"/x","argument":"y"}.
+// The plugin answers on stdout with {"type":"register","commands":["/x"]}, {"type":"message","text":"..."},
+// {"type":"reaction","messageId":"...","reaction":"..."} or {"type":"system","text":"..."}.
+type processPluginMessage struct {
+	Event     *pluginEvent `json:"event,omitempty"`
+	Type      string       `json:"type"`
+	Command   string       `json:"command,omitempty"`
+	Argument  string       `json:"argument,omitempty"`
+	Text      string       `json:"text,omitempty"`
+	MessageID string       `json:"messageId,omitempty"`
+	Reaction  string       `json:"reaction,omitempty"`
+	Commands  []string     `json:"commands,omitempty"`
+}
+
+// processPluginQueueSize is the number of lines buffered for a plugin before lines are dropped,
+// so that a plugin that does not read its input cannot block the chat.
+const processPluginQueueSize = 100
+
+// processPlugin runs an external program speaking the JSON Lines protocol over stdin and stdout.
+type processPlugin struct {
+	stdin io.WriteCloser
+	cmd   *exec.Cmd
+	queue chan processPluginMessage
+	// exited is closed once the plugin exited
+	exited       chan struct{}
+	path         string
+	registered   []string
+	commandMutex sync.Mutex
+	queueMutex   sync.Mutex
+	stopped      bool
+}
+
+// startProcessPlugin starts the plugin executable. Its output is handled until it exits.
+func startProcessPlugin(path string, host pluginHost) (*processPlugin, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", path)
+	} else {
+		cmd = exec.Command(path)
+	}
+	cmd.Stderr = io.Discard
+	// a killed plugin may leave children holding stderr, Wait does not wait for them
+	cmd.WaitDelay = time.Second
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	pp := &processPlugin{
+		path:   path,
+		cmd:    cmd,
+		stdin:  stdin,
+		queue:  make(chan processPluginMessage, processPluginQueueSize),
+		exited: make(chan struct{}),
+	}
+	go pp.writeInput()
+	go pp.readOutput(stdout, host)
+
+	return pp, nil
+}
+
+func (pp *processPlugin) name() string {
+	return filepath.Base(pp.path)
+}
+
+func (pp *processPlugin) commands() []string {
+	pp.commandMutex.Lock()
+	defer pp.commandMutex.Unlock()
+
+	return append([]string(nil), pp.registered...)
+}
+
+func (pp *processPlugin) handleEvent(event pluginEvent, _ pluginHost) {
+	pp.write(processPluginMessage{Type: "event", Event: &event})
+}
+
+func (pp *processPlugin) handleCommand(command string, argument string, _ pluginHost) {
+	pp.write(processPluginMessage{Type: "command", Command: command, Argument: argument})
+}
+
+// write queues a line for the plugin's stdin. The line is dropped if the queue is full.
+func (pp *processPlugin) write(message processPluginMessage) {
+	pp.queueMutex.Lock()
+	defer pp.queueMutex.Unlock()
+
+	if pp.stopped {
+		return
+	}
+
+	select {
+	case pp.queue <- message:
+	default:
+		slog.Warn("plugin is not reading its input, dropping event", "plugin", pp.name(), "type", message.Type)
+	}
+}
+
+// writeInput writes the queued lines to the plugin's stdin until the queue is closed. Once a write
+// failed, e.g. because the plugin exited or was killed, the remaining lines are dropped.
+func (pp *processPlugin) writeInput() {
+	encoder := json.NewEncoder(pp.stdin)
+	var failed bool
+	for message := range pp.queue {
+		if failed {
+			continue
+		}
+		if err := encoder.Encode(message); err != nil {
+			slog.Error("writing to plugin failed", "plugin", pp.name(), "err", err)
+			failed = true
+		}
+	}
+	pp.stdin.Close()
+}
+
+// readOutput executes the actions the plugin writes to stdout.
+func (pp *processPlugin) readOutput(stdout io.Reader, host pluginHost) {
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		var message processPluginMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			host.writeSystemLine(fmt.Sprintf("plugin %s: invalid output: %v", pp.name(), err))
+			continue
+		}
+		pp.execute(message, host)
+	}
+
+	err := pp.cmd.Wait()
+	close(pp.exited)
+	if err == nil {
+		return
+	}
+	// plugins stopped by localterm are not reported in the chat, which is closing
+	if pp.isStopped() {
+		slog.Info("plugin exited", "plugin", pp.name(), "err", err)
+		return
+	}
+	host.writeSystemLine(fmt.Sprintf("plugin %s exited: %v", pp.name(), err))
+}
+
+// execute performs a single action requested by the plugin.
+func (pp *processPlugin) execute(message processPluginMessage, host pluginHost) {
+	switch message.Type {
+	case "register":
+		pp.commandMutex.Lock()
+		for _, command := range message.Commands {
+			if !strings.HasPrefix(command, "/") {
+				command = "/" + command
+			}
+			pp.registered = append(pp.registered, command)
+		}
+		pp.commandMutex.Unlock()
+	case "message":
+		host.sendMessage(message.Text)
+	case "reaction":
+		host.sendReaction(message.MessageID, message.Reaction)
+	case "system":
+		host.writeSystemLine(pp.name() + ": " + message.Text)
+	default:
+		host.writeSystemLine(fmt.Sprintf("plugin %s: unknown output type %q", pp.name(), message.Type))
+	}
+}
+
+// stop closes the plugin's stdin once all queued lines are written, which asks it to exit. A plugin
+// which has not exited within the timeout, e.g. because it does not read its input, is killed.
+func (pp *processPlugin) stop(timeout time.Duration) {
+	pp.queueMutex.Lock()
+	if !pp.stopped {
+		pp.stopped = true
+		close(pp.queue)
+	}
+	pp.queueMutex.Unlock()
+
+	select {
+	case <-pp.exited:
+		return
+	case <-time.After(timeout):
+	}
+
+	slog.Warn("plugin did not exit in time, killing it", "plugin", pp.name())
+	if err := pp.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		slog.Error("killing plugin failed", "plugin", pp.name(), "err", err)
+	}
+}
+
+// isStopped reports whether stop was called.
+func (pp *processPlugin) isStopped() bool {
+	pp.queueMutex.Lock()
+	defer pp.queueMutex.Unlock()
+
+	return pp.stopped
+}
+
+// stopProcessPlugins stops all registered external plugins and waits until they exited or were
+// killed after the timeout.
+func stopProcessPlugins(timeout time.Duration) {
+	var wg sync.WaitGroup
+	for _, p := range getPlugins() {
+		if pp, ok := p.(*processPlugin); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pp.stop(timeout)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// getPluginDir returns the directory external plugins are loaded from.
+func getPluginDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".localchat", "plugins"), nil
+}
+
+// startProcessPlugins starts every executable in ~/.localchat/plugins/ and registers it. It is
+// called before the GUI runs, so the plugins which failed to start are returned, one error each,
+// instead of being written to the chat view.
+func startProcessPlugins(host pluginHost) error {
+	pluginDir, err := getPluginDir()
+	if err != nil {
+		return fmt.Errorf("plugins could not be loaded: %w", err)
+	}
+
+	entries, err := os.ReadDir(pluginDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("plugins could not be loaded: %w", err)
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() || (runtime.GOOS != "windows" && info.Mode()&0o111 == 0) {
+			continue
+		}
+
+		pp, err := startProcessPlugin(filepath.Join(pluginDir, entry.Name()), host)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s failed to start: %w", entry.Name(), err))
+			continue
+		}
+		registerPlugin(pp)
+	}
+
+	return errors.Join(errs...)
+}
+
+// sendMessage sends a plain message on behalf of a plugin.
+func (app *app) sendMessage(text string) {
+	app.ui.QueueUpdate(func() {
+		if err := app.sendMessagePayload(newMessagePayload(text)); err != nil {
+			slog.Error("writing messagePayload failed", "err", err)
+		}
+	})
+}
+
+// sendReaction sends a reaction on behalf of a plugin.
+func (app *app) sendReaction(messageDbID string, reaction string) {
+	app.ui.QueueUpdate(func() {
+		if err := writeJSON(app.conn, newReactionTogglePayload(messageDbID, reaction)); err != nil {
+			slog.Error("writing reactionPayload failed", "err", err)
+		}
+	})
+}
+
+// writeSystemLine prints a local-only line on behalf of a plugin.
+func (app *app) writeSystemLine(text string) {
+	app.ui.QueueUpdateDraw(func() {
+		writeSystemLine(text)
+	})
+}
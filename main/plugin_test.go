@@ -0,0 +1,164 @@
+// main package
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPluginHost struct {
+	messages    []string
+	reactions   []string
+	systemLines []string
+	mu          sync.Mutex
+}
+
+func (m *mockPluginHost) sendMessage(text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, text)
+}
+
+func (m *mockPluginHost) sendReaction(messageDbID string, reaction string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reactions = append(m.reactions, messageDbID+" "+reaction)
+}
+
+func (m *mockPluginHost) writeSystemLine(text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.systemLines = append(m.systemLines, text)
+}
+
+func (m *mockPluginHost) getMessages() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.messages...)
+}
+
+// echoPlugin answers every message containing "ping" with "pong".
+type echoPlugin struct{}
+
+func (ep *echoPlugin) name() string       { return "echo" }
+func (ep *echoPlugin) commands() []string { return []string{"/echo"} }
+
+func (ep *echoPlugin) handleEvent(event pluginEvent, host pluginHost) {
+	if event.Message != nil && event.Message.Text == "ping" {
+		host.sendMessage("pong")
+	}
+}
+
+func (ep *echoPlugin) handleCommand(_ string, argument string, host pluginHost) {
+	host.writeSystemLine(argument)
+}
+
+func registerTestPlugin(t *testing.T, p plugin) {
+	t.Helper()
+	registerPlugin(p)
+	t.Cleanup(func() {
+		pluginMutex.Lock()
+		defer pluginMutex.Unlock()
+		plugins = nil
+	})
+}
+
+func TestDispatchToPlugins(t *testing.T) {
+	registerTestPlugin(t, &echoPlugin{})
+	host := &mockPluginHost{}
+
+	payload, err := json.Marshal(newTestMessagePayload("1", "ping"))
+	assert.NoError(t, err)
+
+	dispatchToPlugins(messageTypeConst, payload, host)
+	dispatchToPlugins(typingIndicatorTypeConst, []byte(`{"payloadType":5}`), host)
+	assert.Equal(t, []string{"pong"}, host.getMessages())
+
+	p, ok := findPluginCommand("/echo")
+	assert.True(t, ok)
+	p.handleCommand("/echo", "hello", host)
+	assert.Equal(t, []string{"hello"}, host.systemLines)
+
+	_, ok = findPluginCommand("/unknown")
+	assert.False(t, ok)
+}
+
+func TestProcessPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell script")
+	}
+
+	script := `#!/bin/sh
+echo '{"type":"register","commands":["deploy"]}'
+while read -r line; do
+	case "$line" in
+		*'"type":"command"'*) echo '{"type":"message","text":"deploying"}' ;;
+		*'"type":"event"'*) echo '{"type":"reaction","messageId":"42","reaction":"👀"}' ;;
+	esac
+done
+`
+	path := filepath.Join(t.TempDir(), "deploy-bot")
+	assert.NoError(t, os.WriteFile(path, []byte(script), 0o700))
+
+	host := &mockPluginHost{}
+	pp, err := startProcessPlugin(path, host)
+	assert.NoError(t, err)
+	defer pp.stop(time.Second)
+
+	assert.Eventually(t, func() bool {
+		return len(pp.commands()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"/deploy"}, pp.commands())
+
+	pp.handleCommand("/deploy", "prod", host)
+	assert.Eventually(t, func() bool {
+		return len(host.getMessages()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"deploying"}, host.getMessages())
+
+	pp.handleEvent(newPluginEvent(typingIndicatorTypeConst, []byte(`{"payloadType":5}`)), host)
+	assert.Eventually(t, func() bool {
+		host.mu.Lock()
+		defer host.mu.Unlock()
+		return len(host.reactions) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"42 👀"}, host.reactions)
+}
+
+func TestProcessPlugin_StopKillsPluginNotReadingInput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell script")
+	}
+
+	path := filepath.Join(t.TempDir(), "stuck-bot")
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\nexec sleep 60\n"), 0o700))
+
+	host := &mockPluginHost{}
+	pp, err := startProcessPlugin(path, host)
+	assert.NoError(t, err)
+
+	// more input than the pipe buffer holds, the writer blocks until the plugin is killed
+	event := newPluginEvent(messageTypeConst, []byte(`{"payloadType":1,"text":"`+strings.Repeat("x", 64<<10)+`"}`))
+	for i := 0; i < 4; i++ {
+		pp.handleEvent(event, host)
+	}
+
+	start := time.Now()
+	pp.stop(100 * time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second)
+
+	select {
+	case <-pp.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("plugin was not killed")
+	}
+	assert.Empty(t, host.systemLines)
+}
@@ -0,0 +1,93 @@
+// main package
+package main
+
+// effectiveReactions returns the reactions in the order they were added, without duplicates of the
+// same reaction by the same client. A reaction marked as removed cancels the earlier one of the
+// same client with the same content.
+func effectiveReactions(reactions []reactionType) []reactionType {
+	type reactionKey struct {
+		clientID string
+		content  string
+	}
+
+	var effective []reactionType
+	seen := make(map[reactionKey]int)
+
+	for _, reaction := range reactions {
+		key := reactionKey{clientID: reaction.ReactionClientID, content: reaction.ReactionContext}
+		index, found := seen[key]
+
+		if reaction.ReactionRemoved {
+			if found {
+				effective = append(effective[:index], effective[index+1:]...)
+				delete(seen, key)
+				for k, i := range seen {
+					if i > index {
+						seen[k] = i - 1
+					}
+				}
+			}
+			continue
+		}
+
+		if !found {
+			seen[key] = len(effective)
+			effective = append(effective, reaction)
+		}
+	}
+
+	return effective
+}
+
+// reactionSummary is a reaction content with the number of clients who added it.
+type reactionSummary struct {
+	content string
+	count   int
+	own     bool
+}
+
+// summarizeReactions groups the effective reactions by content in the order they were first added.
+// Reactions of ownID are marked, so they can be highlighted.
+func summarizeReactions(reactions []reactionType, ownID string) []reactionSummary {
+	var summaries []reactionSummary
+	indices := make(map[string]int)
+
+	for _, reaction := range effectiveReactions(reactions) {
+		index, found := indices[reaction.ReactionContext]
+		if !found {
+			index = len(summaries)
+			indices[reaction.ReactionContext] = index
+			summaries = append(summaries, reactionSummary{content: reaction.ReactionContext})
+		}
+		summaries[index].count++
+		if ownID != "" && reaction.ReactionClientID == ownID {
+			summaries[index].own = true
+		}
+	}
+
+	return summaries
+}
+
+// hasOwnReaction reports whether this client currently reacts to the message with the content.
+func hasOwnReaction(payload messagePayload, reaction string) bool {
+	if payload.ReactionType == nil {
+		return false
+	}
+
+	for _, r := range effectiveReactions(*payload.ReactionType) {
+		if r.ReactionClientID == envVars.ID && r.ReactionContext == reaction {
+			return true
+		}
+	}
+	return false
+}
+
+// newReactionTogglePayload builds the payload adding the reaction to the message with the given id,
+// or removing it if this client already reacted with the same content.
+func newReactionTogglePayload(messageDbID string, reaction string) reactionPayload {
+	payload := newReactionPayload(messageDbID, reaction)
+	if message, ok := getMessageByDbIDFromCache(messageDbID); ok && hasOwnReaction(message, reaction) {
+		payload.ReactionRemoved = true
+	}
+	return payload
+}
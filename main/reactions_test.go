@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectiveReactions(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []reactionType
+		want  []reactionType
+	}{
+		{
+			name:  "no reactions",
+			input: nil,
+			want:  nil,
+		},
+		{
+			name: "duplicate of the same client is dropped",
+			input: []reactionType{
+				{ReactionClientID: "a", ReactionContext: "👍"},
+				{ReactionClientID: "a", ReactionContext: "👍"},
+			},
+			want: []reactionType{{ReactionClientID: "a", ReactionContext: "👍"}},
+		},
+		{
+			name: "same reaction of different clients is kept",
+			input: []reactionType{
+				{ReactionClientID: "a", ReactionContext: "👍"},
+				{ReactionClientID: "b", ReactionContext: "👍"},
+			},
+			want: []reactionType{
+				{ReactionClientID: "a", ReactionContext: "👍"},
+				{ReactionClientID: "b", ReactionContext: "👍"},
+			},
+		},
+		{
+			name: "removal cancels the earlier reaction",
+			input: []reactionType{
+				{ReactionClientID: "a", ReactionContext: "👍"},
+				{ReactionClientID: "b", ReactionContext: "🎉"},
+				{ReactionClientID: "a", ReactionContext: "👍", ReactionRemoved: true},
+				{ReactionClientID: "b", ReactionContext: "👍"},
+			},
+			want: []reactionType{
+				{ReactionClientID: "b", ReactionContext: "🎉"},
+				{ReactionClientID: "b", ReactionContext: "👍"},
+			},
+		},
+		{
+			name: "reaction after removal is added again",
+			input: []reactionType{
+				{ReactionClientID: "a", ReactionContext: "👍"},
+				{ReactionClientID: "a", ReactionContext: "👍", ReactionRemoved: true},
+				{ReactionClientID: "a", ReactionContext: "👍"},
+			},
+			want: []reactionType{{ReactionClientID: "a", ReactionContext: "👍"}},
+		},
+		{
+			name: "removal without reaction is ignored",
+			input: []reactionType{
+				{ReactionClientID: "a", ReactionContext: "👍", ReactionRemoved: true},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, effectiveReactions(tt.input))
+		})
+	}
+}
+
+func TestSummarizeReactions(t *testing.T) {
+	reactions := []reactionType{
+		{ReactionClientID: "a", ReactionContext: "👍"},
+		{ReactionClientID: "me", ReactionContext: "🎉"},
+		{ReactionClientID: "b", ReactionContext: "👍"},
+		{ReactionClientID: "b", ReactionContext: "👍"},
+	}
+
+	assert.Equal(t, []reactionSummary{
+		{content: "👍", count: 2},
+		{content: "🎉", count: 1, own: true},
+	}, summarizeReactions(reactions, "me"))
+}
+
+func TestCheckForReactionsHighlightsOwnReactions(t *testing.T) {
+	originalID := envVars.ID
+	envVars.ID = "me"
+	t.Cleanup(func() { envVars.ID = originalID })
+
+	reactions := []reactionType{
+		{ReactionClientID: "a", ReactionContext: "👍"},
+		{ReactionClientID: "me", ReactionContext: "👍"},
+		{ReactionClientID: "a", ReactionContext: "[red]"},
+	}
+
+	assert.Equal(t, "\n"+margin+"[#8B8000]└ [ [::r]👍×2[::-] [red[] ][-]", checkForReactions(reactions))
+}
+
+func TestNewReactionTogglePayload(t *testing.T) {
+	originalID := envVars.ID
+	envVars.ID = "me"
+	t.Cleanup(func() { envVars.ID = originalID })
+
+	message := newTestMessagePayload("other", "hello")
+	message.MessageType.MessageDbID = "db-1"
+	message.ReactionType = &[]reactionType{{ReactionClientID: "me", ReactionContext: "👍"}}
+	resetMessageCache()
+	appendMessageToCache(message)
+	t.Cleanup(resetMessageCache)
+
+	assert.True(t, newReactionTogglePayload("db-1", "👍").ReactionRemoved)
+	assert.False(t, newReactionTogglePayload("db-1", "🎉").ReactionRemoved)
+	assert.False(t, newReactionTogglePayload("unknown", "👍").ReactionRemoved)
+}
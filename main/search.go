@@ -0,0 +1,239 @@
+// main package
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	searchPageName        = "search"
+	archiveSearchPageName = "archive-search"
+)
+
+// searchState holds the matches of the current search. It is only accessed from the UI goroutine.
+type searchState struct {
+	term    string
+	matches []int
+	current int
+}
+
+var currentSearch searchState
+
+// searchTagRegex matches the tags in rendered text, which are skipped when matches are highlighted.
+// Escaped brackets like "[red[]" are matched as a whole, so no tag is inserted into them.
+var searchTagRegex = regexp.MustCompile(`\[[a-zA-Z0-9_,;: \-."#]*\[+\]|\[[^\[\]]*\]`)
+
+// parseSearchArgument splits the argument of /search into the search term and whether the local
+// archive is searched, e.g. "-a build" searches the archive for "build".
+func parseSearchArgument(argument string) (term string, archive bool) {
+	if argument == "-a" {
+		return "", true
+	}
+	if term, found := strings.CutPrefix(argument, "-a "); found {
+		return strings.TrimSpace(term), true
+	}
+	return argument, false
+}
+
+// messageMatchesSearch reports whether the decoded message, its quote or the sender's username
+// contain the search term, ignoring case.
+func messageMatchesSearch(payload messagePayload, term string) bool {
+	term = strings.ToLower(term)
+
+	if decodedString, err := decodeBase64ToString(payload.MessageType.MessageContext); err == nil &&
+		strings.Contains(strings.ToLower(decodedString), term) {
+		return true
+	}
+
+	if payload.QuoteType != nil {
+		if decodedQuote, err := decodeBase64ToString(payload.QuoteType.QuoteMessageContext); err == nil &&
+			strings.Contains(strings.ToLower(decodedQuote), term) {
+			return true
+		}
+	}
+
+	return strings.Contains(strings.ToLower(getUsernameForID(payload.ClientType.ClientDbID)), term)
+}
+
+// searchMessages returns the indices of all messages matching the search term, in order.
+func searchMessages(messages []messagePayload, term string) []int {
+	if strings.TrimSpace(term) == "" {
+		return nil
+	}
+
+	var matches []int
+	for index, payload := range messages {
+		if messageMatchesSearch(payload, term) {
+			matches = append(matches, index)
+		}
+	}
+
+	return matches
+}
+
+// highlightSearchTerm marks every match of the search term in the rendered text, ignoring case. The
+// matches are reversed and underlined, so they also stand out in the highlighted message.
+// Tags in text are left untouched, matches spanning a tag are not marked.
+func highlightSearchTerm(text string, term string) string {
+	if strings.TrimSpace(term) == "" {
+		return text
+	}
+	termRegex := regexp.MustCompile("(?i)" + regexp.QuoteMeta(tview.Escape(term)))
+	highlight := func(text string) string {
+		return termRegex.ReplaceAllString(text, "[::ru]${0}[::RU]")
+	}
+
+	var builder strings.Builder
+	last := 0
+	for _, loc := range searchTagRegex.FindAllStringIndex(text, -1) {
+		builder.WriteString(highlight(text[last:loc[0]]))
+		builder.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	builder.WriteString(highlight(text[last:]))
+
+	return builder.String()
+}
+
+// runSearch searches the loaded messages and highlights the newest match. The messages are
+// rendered again to mark the matched text, see highlightSearchTerm.
+func (app *app) runSearch(term string) {
+	previousTerm := currentSearch.term
+
+	// matching replies highlight the parent of their thread
+	messages := getMessagesFromCache()
+	currentSearch = searchState{
+		term:    term,
+		matches: toVisibleIndices(messages, searchMessages(messages, term)),
+	}
+	currentSearch.current = len(currentSearch.matches) - 1
+
+	if term != previousTerm {
+		app.redrawAllMessages()
+	}
+	highlightCurrentMatch()
+}
+
+// clearSearch removes the highlights of the current search.
+func (app *app) clearSearch() {
+	currentSearch = searchState{}
+	chatView.Highlight()
+	app.redrawAllMessages()
+}
+
+// stepSearch moves the highlight to the previous (negative step) or next (positive step) match.
+func stepSearch(step int) {
+	if len(currentSearch.matches) == 0 {
+		return
+	}
+
+	currentSearch.current = (currentSearch.current + step + len(currentSearch.matches)) % len(currentSearch.matches)
+	highlightCurrentMatch()
+}
+
+// highlightCurrentMatch highlights the current match in the chat view and scrolls to it.
+func highlightCurrentMatch() {
+	if len(currentSearch.matches) == 0 {
+		chatView.Highlight()
+		return
+	}
+
+	chatView.Highlight(messageRegionID(currentSearch.matches[currentSearch.current])).ScrollToHighlight()
+	chatFollowsEnd = false
+}
+
+// generateSearchLabel returns the label of the search bar, e.g. "Search (2/5): ".
+func generateSearchLabel() string {
+	if currentSearch.term == "" {
+		return "  Search: "
+	}
+	if len(currentSearch.matches) == 0 {
+		return "  Search (0/0): "
+	}
+	return fmt.Sprintf("  Search (%d/%d): ", currentSearch.current+1, len(currentSearch.matches))
+}
+
+// showSearchBar opens the search bar above the input field, optionally prefilled with a search term.
+// Enter and Up step to older matches, Down to newer ones, Escape closes the search.
+func (app *app) showSearchBar(term string) {
+	searchField := tview.NewInputField().
+		SetLabelColor(themeColor(currentTheme.Accent)).
+		SetFieldBackgroundColor(themeColor(currentTheme.CommandBackground))
+
+	searchField.SetChangedFunc(func(text string) {
+		app.runSearch(text)
+		searchField.SetLabel(generateSearchLabel())
+	})
+
+	searchField.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			stepSearch(-1)
+			searchField.SetLabel(generateSearchLabel())
+		case tcell.KeyEscape:
+			app.clearSearch()
+			app.hideOverlay(searchPageName)
+		}
+	})
+
+	searchField.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp:
+			stepSearch(-1)
+		case tcell.KeyDown:
+			stepSearch(1)
+		default:
+			return event
+		}
+		searchField.SetLabel(generateSearchLabel())
+		return nil
+	})
+
+	searchField.SetText(term)
+	searchField.SetLabel(generateSearchLabel())
+
+	searchBar := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(searchField, 1, 0, true).
+		AddItem(nil, 2, 0, false)
+
+	pages.AddPage(searchPageName, searchBar, true, true)
+	app.ui.SetFocus(searchField)
+}
+
+// showArchiveSearchResults opens an overlay listing all archived messages of all sessions
+// matching the search term. The overlay is closed with Escape.
+func (app *app) showArchiveSearchResults(term string) {
+	messages := app.archive.getMessages()
+	matches := searchMessages(messages, term)
+	usernames := getClientUsernames()
+	ownUsername := getThisClientUsername()
+
+	textView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
+	textView.SetBorder(true).
+		SetTitle(fmt.Sprintf(" archive search for %q (%d) ", term, len(matches)))
+
+	if len(matches) == 0 {
+		fmt.Fprint(textView, colorTag(currentTheme.Muted)+"no archived message found[-]")
+	}
+	for _, index := range matches {
+		fmt.Fprintln(textView, formatMessageSummary(messages[index], usernames, ownUsername))
+	}
+	textView.ScrollToEnd()
+
+	textView.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			app.hideOverlay(archiveSearchPageName)
+		}
+	})
+
+	app.showOverlay(archiveSearchPageName, textView)
+}
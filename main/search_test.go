@@ -0,0 +1,88 @@
+// main package
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchMessages(t *testing.T) {
+	setTestClientList(t,
+		client{ClientDbID: "1", ClientUsername: "Alice"},
+		client{ClientDbID: "2", ClientUsername: "Bob"})
+
+	quoted := newTestMessagePayload("2", "sure")
+	quoted.QuoteType = &quoteType{
+		QuoteClientID:       "1",
+		QuoteMessageContext: base64.StdEncoding.EncodeToString([]byte("Lunch at noon?")),
+	}
+
+	messages := []messagePayload{
+		newTestMessagePayload("1", "Lunch at noon?"),
+		newTestMessagePayload("2", "the build is broken"),
+		quoted,
+		newTestMessagePayload("1", "fixed the BUILD"),
+	}
+
+	tests := []struct {
+		name string
+		term string
+		want []int
+	}{
+		{name: "empty term", term: " ", want: nil},
+		{name: "no match", term: "coffee", want: nil},
+		{name: "case insensitive", term: "build", want: []int{1, 3}},
+		{name: "quote", term: "lunch", want: []int{0, 2}},
+		{name: "username", term: "bob", want: []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, searchMessages(messages, tt.term))
+		})
+	}
+}
+
+func TestHighlightSearchTerm(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		term string
+		want string
+	}{
+		{name: "no term", text: "the build", term: "", want: "the build"},
+		{name: "case insensitive", text: "Build the build", term: "build", want: "[::ru]Build[::RU] the [::ru]build[::RU]"},
+		{name: "tags are skipped", text: "[::b]build[::-] [red]red[-]", term: "red", want: "[::b]build[::-] [red][::ru]red[::RU][-]"},
+		{name: "escaped brackets are kept", text: "see [red[] now", term: "red", want: "see [red[] now"},
+		{name: "brackets in the term", text: "see [red[] now", term: "[red]", want: "see [red[] now"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, highlightSearchTerm(tt.text, tt.term))
+		})
+	}
+}
+
+func TestParseSearchArgument(t *testing.T) {
+	tests := []struct {
+		argument    string
+		wantTerm    string
+		wantArchive bool
+	}{
+		{argument: "build", wantTerm: "build"},
+		{argument: "-a build red", wantTerm: "build red", wantArchive: true},
+		{argument: "-a", wantTerm: "", wantArchive: true},
+		{argument: "-ab", wantTerm: "-ab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.argument, func(t *testing.T) {
+			term, archive := parseSearchArgument(tt.argument)
+			assert.Equal(t, tt.wantTerm, term)
+			assert.Equal(t, tt.wantArchive, archive)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// main package
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const selectionHint = "j/k move, q quote, r react, R react with text, t thread, y copy, o open image, Escape back to the input"
+
+// selectedMessage is the index of the message selected in the chat view, -1 if no message is selected
+var selectedMessage = -1
+
+// moveSelection returns the index of the message step messages away from the selected one,
+// limited to the loaded messages. It returns -1 if there are no messages.
+func moveSelection(selected int, step int, count int) int {
+	if count == 0 {
+		return -1
+	}
+	if selected < 0 {
+		return count - 1
+	}
+	return max(0, min(count-1, selected+step))
+}
+
+// formatMessageIndex returns the three digit index used by commands, e.g. "042".
+func formatMessageIndex(index int) string {
+	return fmt.Sprintf("%03d", index)
+}
+
+// copyToClipboard copies the text to the system clipboard of the terminal using OSC 52. It must be
+// called from the UI goroutine, like the key handlers do, so the sequence is not written during a
+// screen update.
+func copyToClipboard(out io.Writer, text string) error {
+	_, err := fmt.Fprintf(out, "\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
+
+// getImageDir returns the directory images are saved in before they are opened.
+func getImageDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".localchat", "images"), nil
+}
+
+// saveImage writes the base64 encoded image of a message into dir and returns its path.
+func saveImage(dir string, image imageType) (string, error) {
+	data := image.Data
+	// data URIs like "data:image/png;base64,..." are accepted as well
+	if _, encoded, found := strings.Cut(data, ";base64,"); found {
+		data = encoded
+	}
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", err
+	}
+
+	extension := strings.TrimPrefix(image.Type, "image/")
+	if extension == "" || strings.ContainsAny(extension, `/\.`) {
+		extension = "img"
+	}
+	name := filepath.Base(image.ImageDbID)
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		name = GenerateRandomID()
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name+"."+extension)
+
+	return path, os.WriteFile(path, decoded, 0o600)
+}
+
+// openImage saves the image of the message and opens it with the default viewer.
+func openImage(payload messagePayload) error {
+	if payload.ImageType == nil || payload.ImageType.Data == "" {
+		return errors.New("the message has no image")
+	}
+
+	imageDir, err := getImageDir()
+	if err != nil {
+		return err
+	}
+	path, err := saveImage(imageDir, *payload.ImageType)
+	if err != nil {
+		return err
+	}
+
+	cmd := newOpenCommand(path)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+
+	return nil
+}
+
+// selectMessage highlights the message with the given index and scrolls to it.
+func selectMessage(index int) {
+	selectedMessage = index
+	if index < 0 {
+		chatView.Highlight()
+		return
+	}
+	chatView.Highlight(messageRegionID(index)).ScrollToHighlight()
+	chatFollowsEnd = false
+}
+
+// enterSelectionMode moves the focus to the chat view and selects the newest message.
+func (app *app) enterSelectionMode() {
+	selectMessage(moveVisibleSelection(-1, 0, getMessagesFromCache()))
+	if selectedMessage < 0 {
+		showInputHint("there are no messages to select")
+		return
+	}
+
+	showInputHint(selectionHint)
+	app.ui.SetFocus(chatView)
+}
+
+// leaveSelectionMode removes the selection and gives the focus back to the input field,
+// optionally prefilled with text.
+func (app *app) leaveSelectionMode(text string) {
+	selectMessage(-1)
+	clearInputHint()
+	if text != "" {
+		inputField.SetText(text)
+	}
+	app.ui.SetFocus(inputField)
+}
+
+// handleSelectionKey handles the keys of the message selection mode. It returns nil if the
+// event was handled.
+func (app *app) handleSelectionKey(event *tcell.EventKey) *tcell.EventKey {
+	if selectedMessage < 0 {
+		return event
+	}
+
+	messages := getMessagesFromCache()
+	count := len(messages)
+	index := formatMessageIndex(selectedMessage)
+	payload := getMessageFromCache(selectedMessage)
+
+	var r rune
+	if event.Key() == tcell.KeyRune {
+		r = event.Rune()
+	}
+
+	var err error
+	switch {
+	case event.Key() == tcell.KeyEscape, r == 'i':
+		app.leaveSelectionMode("")
+	case event.Key() == tcell.KeyDown, r == 'j':
+		selectMessage(moveVisibleSelection(selectedMessage, 1, messages))
+	case event.Key() == tcell.KeyUp, r == 'k':
+		selectMessage(moveVisibleSelection(selectedMessage, -1, messages))
+	case event.Key() == tcell.KeyHome, r == 'g':
+		selectMessage(moveVisibleSelection(selectedMessage, -count, messages))
+	case event.Key() == tcell.KeyEnd, r == 'G':
+		selectMessage(moveVisibleSelection(selectedMessage, count, messages))
+	case r == 'q':
+		app.leaveSelectionMode("/q " + index + " ")
+	case r == 'r':
+		app.reactWithPicker(payload)
+	case r == 'R':
+		app.leaveSelectionMode("/r " + index + " ")
+	case r == 't':
+		threadIndex := selectedMessage
+		app.leaveSelectionMode("")
+		app.showThread(threadIndex)
+	case r == 'y':
+		var text string
+		if text, err = decodeBase64ToString(payload.MessageType.MessageContext); err == nil {
+			if err = copyToClipboard(os.Stdout, text); err == nil {
+				showInputHint("copied message " + index)
+			}
+		}
+	case r == 'o':
+		if err = openImage(payload); err == nil {
+			showInputHint("opened the image of message " + index)
+		}
+	default:
+		return event
+	}
+
+	if err != nil {
+		showInputHint(err.Error())
+	}
+	return nil
+}
+
+// reactWithPicker opens the emoji picker and reacts to the message with the picked emoji.
+// The selection mode continues afterwards.
+func (app *app) reactWithPicker(payload messagePayload) {
+	app.showEmojiPicker(func(emoji string) {
+		app.ui.SetFocus(chatView)
+		if emoji == "" {
+			return
+		}
+		if err := writeJSON(app.conn, newReactionTogglePayload(payload.MessageType.MessageDbID, emoji)); err != nil {
+			showInputHint(err.Error())
+		}
+	})
+}
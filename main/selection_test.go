@@ -0,0 +1,84 @@
+// main package
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoveSelection(t *testing.T) {
+	tests := []struct {
+		name     string
+		selected int
+		step     int
+		count    int
+		want     int
+	}{
+		{name: "no messages", selected: -1, step: 0, count: 0, want: -1},
+		{name: "start at newest", selected: -1, step: 0, count: 5, want: 4},
+		{name: "older", selected: 4, step: -1, count: 5, want: 3},
+		{name: "newer", selected: 3, step: 1, count: 5, want: 4},
+		{name: "stop at newest", selected: 4, step: 1, count: 5, want: 4},
+		{name: "stop at oldest", selected: 0, step: -1, count: 5, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, moveSelection(tt.selected, tt.step, tt.count))
+		})
+	}
+}
+
+func TestCopyToClipboard(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, copyToClipboard(&out, "hi"))
+	assert.Equal(t, "\x1b]52;c;aGk=\x07", out.String())
+}
+
+func TestSaveImage(t *testing.T) {
+	dir := t.TempDir()
+
+	path, err := saveImage(dir, imageType{ImageDbID: "../abc", Type: "image/png", Data: "data:image/png;base64,aGk="})
+	assert.NoError(t, err)
+	assert.Equal(t, dir+string(os.PathSeparator)+"abc.png", path)
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", string(content))
+
+	_, err = saveImage(dir, imageType{ImageDbID: "x", Data: "not base64!"})
+	assert.Error(t, err)
+
+	assert.Error(t, openImage(newTestMessagePayload("1", "no image")))
+}
+
+func TestHandleSelectionKey(t *testing.T) {
+	resetMessageCache()
+	t.Cleanup(resetMessageCache)
+	chatView = tview.NewTextView().SetRegions(true)
+	typingView = tview.NewTextView()
+	for i := 0; i < 3; i++ {
+		index := appendMessageToCache(newTestMessagePayload("other", "text"))
+		fmt.Fprintf(chatView, "[\"%s\"]text[\"\"]\n", messageRegionID(index))
+	}
+	app := &app{ui: tview.NewApplication()}
+
+	app.enterSelectionMode()
+	t.Cleanup(func() { selectedMessage = -1 })
+	assert.Equal(t, 2, selectedMessage)
+	assert.Equal(t, []string{messageRegionID(2)}, chatView.GetHighlights())
+
+	assert.Nil(t, app.handleSelectionKey(tcell.NewEventKey(tcell.KeyRune, 'k', tcell.ModNone)))
+	assert.Nil(t, app.handleSelectionKey(tcell.NewEventKey(tcell.KeyRune, 'k', tcell.ModNone)))
+	assert.Equal(t, 0, selectedMessage)
+	assert.Nil(t, app.handleSelectionKey(tcell.NewEventKey(tcell.KeyRune, 'j', tcell.ModNone)))
+	assert.Equal(t, []string{messageRegionID(1)}, chatView.GetHighlights())
+
+	event := tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModNone)
+	assert.Equal(t, event, app.handleSelectionKey(event))
+}
@@ -0,0 +1,108 @@
+// main package
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	exitCodeOK = 0
+	// exitCodeError is used if localterm could not start or messages were not delivered
+	exitCodeError = 1
+	// exitCodeUsage is used for invalid flags
+	exitCodeUsage = 2
+	// exitCodeConnectionLost is used if the server closed the connection
+	exitCodeConnectionLost = 3
+
+	// flushTimeout is how long quitting waits for pending messages to be acknowledged
+	flushTimeout = 3 * time.Second
+	// closeTimeout is how long quitting waits for the server to close the connection
+	closeTimeout = time.Second
+	// pluginStopTimeout is how long quitting waits for plugins to exit before they are killed
+	pluginStopTimeout = 2 * time.Second
+)
+
+var (
+	// errQuit is the cause of quitting on request of the user
+	errQuit = errors.New("quit")
+	// errConnectionLost is the cause of quitting after the server closed the connection
+	errConnectionLost = errors.New("connection lost")
+)
+
+// quit ends localterm: the GUI stops and the session is shut down, see shutdown. Only the first
+// cause counts, use errQuit if the user quits.
+func (app *app) quit(cause error) {
+	if app.cancel != nil {
+		app.cancel(cause)
+	}
+}
+
+// stopWhenQuitting stops the GUI as soon as the app quits. The stop is queued, so it also takes
+// effect if the app quits before the GUI is running.
+func (app *app) stopWhenQuitting() {
+	<-app.ctx.Done()
+	app.ui.QueueUpdate(app.ui.Stop)
+}
+
+// shutdown ends the session after the GUI stopped. The other clients are told that this client
+// stopped typing, pending messages are given some time to be acknowledged and the connection is
+// closed normally, then the plugins are stopped, or killed if they do not exit in time, and the
+// draft and the archive are saved. It returns the exit code.
+func (app *app) shutdown(connectionDone <-chan struct{}) int {
+	cause := context.Cause(app.ctx)
+
+	if !errors.Is(cause, errConnectionLost) {
+		if err := writeJSON(app.conn, newTypingPayload(false)); err != nil {
+			slog.Error("writing typingPayload failed", "err", err)
+		}
+		waitForPendingMessages(flushTimeout)
+		app.closeConnection(connectionDone, closeTimeout)
+	}
+	undelivered := len(getUndeliveredMessages())
+	resetOutbox()
+
+	stopProcessPlugins(pluginStopTimeout)
+
+	// keep the unsent input for the next session
+	if inputField != nil {
+		if err := saveDraft(inputField.GetText()); err != nil {
+			slog.Warn("saving the draft failed", "err", err)
+		}
+	}
+
+	if err := app.archive.close(); err != nil {
+		slog.Warn("closing message archive failed", "err", err)
+	}
+
+	slog.Info("quit", "cause", cause, "undelivered", undelivered)
+
+	// the terminal belongs to the shell again, problems are reported there
+	if undelivered > 0 {
+		fmt.Fprintf(os.Stderr, "%d message(s) were not delivered\n", undelivered)
+	}
+	if cause != nil && !errors.Is(cause, errQuit) {
+		fmt.Fprintln(os.Stderr, cause)
+	}
+
+	return getExitCode(cause, undelivered)
+}
+
+// getExitCode returns the exit code for the cause of quitting and the number of messages which
+// were not delivered.
+func getExitCode(cause error, undelivered int) int {
+	switch {
+	case errors.Is(cause, errConnectionLost):
+		return exitCodeConnectionLost
+	case cause != nil && !errors.Is(cause, errQuit):
+		return exitCodeError
+	case undelivered > 0:
+		return exitCodeError
+	default:
+		return exitCodeOK
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetExitCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		cause       error
+		undelivered int
+		want        int
+	}{
+		{name: "quit", cause: errQuit, want: exitCodeOK},
+		{name: "quit with undelivered messages", cause: errQuit, undelivered: 2, want: exitCodeError},
+		{name: "connection lost", cause: fmt.Errorf("%w: EOF", errConnectionLost), want: exitCodeConnectionLost},
+		{name: "gui failed", cause: errors.New("terminal not supported"), want: exitCodeError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getExitCode(tt.cause, tt.undelivered))
+		})
+	}
+}
+
+func TestApp_Quit(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	quitting := &app{ctx: ctx, cancel: cancel}
+
+	quitting.quit(fmt.Errorf("%w: EOF", errConnectionLost))
+	quitting.quit(errQuit)
+
+	// the first cause counts
+	assert.ErrorIs(t, context.Cause(quitting.ctx), errConnectionLost)
+
+	// apps without context do not panic
+	(&app{}).quit(errQuit)
+}
+
+func TestWaitForPendingMessages(t *testing.T) {
+	t.Cleanup(resetOutbox)
+
+	assert.True(t, waitForPendingMessages(0))
+
+	failed := newTestMessagePayload(envVars.ID, "failed")
+	trackDelivery(failed, time.Hour, nil)
+	markDeliveryFailed(failed.MessageType.MessageDbID)
+	// failed messages are not waited for
+	assert.True(t, waitForPendingMessages(0))
+
+	pending := newTestMessagePayload(envVars.ID, "pending")
+	trackDelivery(pending, time.Hour, nil)
+	assert.False(t, waitForPendingMessages(10*time.Millisecond))
+
+	time.AfterFunc(10*time.Millisecond, func() { markDelivered(pending.MessageType.MessageDbID) })
+	assert.True(t, waitForPendingMessages(time.Second))
+}
+
+func TestNewTypingPayload(t *testing.T) {
+	payload := newTypingPayload(false)
+
+	assert.Equal(t, typingIndicatorTypeConst, payload.PayloadType)
+	assert.Equal(t, envVars.ID, payload.ClientDbID)
+	assert.False(t, payload.IsTyping)
+}
@@ -0,0 +1,93 @@
+// main package
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// sidebarWidth is the width of the user list next to the chat
+const sidebarWidth = 24
+
+var (
+	// chatRow holds the chat view and the sidebar if it is shown
+	chatRow        *tview.Flex
+	sidebarView    *tview.TextView
+	sidebarVisible bool
+)
+
+// createChatRow creates the row of the chat view and the hidden sidebar listing the users.
+func createChatRow() *tview.Flex {
+	sidebarView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
+	sidebarView.SetBorder(true)
+
+	chatRow = tview.NewFlex().
+		AddItem(chatView, 0, 1, false)
+
+	return chatRow
+}
+
+// toggleSidebar shows or hides the user list.
+func toggleSidebar() {
+	if chatRow == nil {
+		return
+	}
+
+	sidebarVisible = !sidebarVisible
+	if sidebarVisible {
+		chatRow.AddItem(sidebarView, sidebarWidth, 0, false)
+		renderSidebar()
+		return
+	}
+	chatRow.RemoveItem(sidebarView)
+}
+
+// getSortedClients returns all registered clients ordered by username.
+func getSortedClients() []client {
+	mutex.Lock()
+	clients := append([]client(nil), clientList.Clients...)
+	mutex.Unlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		return strings.ToLower(clients[i].ClientUsername) < strings.ToLower(clients[j].ClientUsername)
+	})
+	return clients
+}
+
+// isClientTyping reports whether the client is currently typing.
+func isClientTyping(clientID string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, v := range typingClientCache {
+		if v == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// renderSidebar lists the registered users in the sidebar, users who are typing are marked.
+func renderSidebar() {
+	if sidebarView == nil || !sidebarVisible {
+		return
+	}
+
+	clients := getSortedClients()
+	sidebarView.Clear()
+	sidebarView.SetTitle(fmt.Sprintf(" users (%d) ", len(clients)))
+
+	for _, c := range clients {
+		typing := ""
+		if isClientTyping(c.ClientDbID) {
+			typing = " " + colorTag(currentTheme.Muted) + "typing[-]"
+		}
+		fmt.Fprintf(sidebarView, "%s%s[-]%s\n", colorTag(getDisplayColor(c.ClientDbID)),
+			tview.Escape(c.ClientUsername), typing)
+	}
+}
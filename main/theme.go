@@ -0,0 +1,338 @@
+// main package
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const defaultThemeName = "dark"
+
+// theme holds the colors of all widgets and chat elements. Colors are tview color names like
+// "yellow" or hex colors like "#997275".
+type theme struct {
+	Name string `json:"name"`
+
+	// widgets
+	Background         string `json:"background"`
+	ContrastBackground string `json:"contrastBackground"`
+	Text               string `json:"text"`
+	SecondaryText      string `json:"secondaryText"`
+	Border             string `json:"border"`
+	Title              string `json:"title"`
+
+	// chat elements
+	Muted       string `json:"muted"`
+	Accent      string `json:"accent"`
+	Error       string `json:"error"`
+	Quote       string `json:"quote"`
+	Reaction    string `json:"reaction"`
+	Unread      string `json:"unread"`
+	DefaultUser string `json:"defaultUser"`
+	// OwnMention is the foreground and background of mentions of this client, e.g. "black:yellow"
+	OwnMention string `json:"ownMention"`
+
+	// input field
+	InputLabel        string `json:"inputLabel"`
+	InputBackground   string `json:"inputBackground"`
+	InputText         string `json:"inputText"`
+	CommandBackground string `json:"commandBackground"`
+	QuoteInput        string `json:"quoteInput"`
+	ReactionInput     string `json:"reactionInput"`
+	SettingInput      string `json:"settingInput"`
+
+	// code blocks
+	Code        string `json:"code"`
+	CodeBorder  string `json:"codeBorder"`
+	CodeKeyword string `json:"codeKeyword"`
+	CodeString  string `json:"codeString"`
+	CodeNumber  string `json:"codeNumber"`
+	CodeComment string `json:"codeComment"`
+
+	// Colors limits the colors of the theme and of the users to a palette of this size,
+	// 0 uses what the terminal supports
+	Colors int `json:"colors"`
+}
+
+var builtinThemes = map[string]theme{
+	"dark": {
+		Name:               "dark",
+		Background:         "black",
+		ContrastBackground: "blue",
+		Text:               "white",
+		SecondaryText:      "yellow",
+		Border:             "white",
+		Title:              "white",
+		Muted:              "gray",
+		Accent:             "yellow",
+		Error:              "red",
+		Quote:              "#997275",
+		Reaction:           "#8B8000",
+		Unread:             "red",
+		DefaultUser:        "yellow",
+		OwnMention:         "black:yellow",
+		InputLabel:         "greenyellow",
+		InputBackground:    "blueviolet",
+		InputText:          "white",
+		CommandBackground:  "darkslategray",
+		QuoteInput:         "darkorange",
+		ReactionInput:      "green",
+		SettingInput:       "yellow",
+		Code:               "orange",
+		CodeBorder:         "gray",
+		CodeKeyword:        "yellow",
+		CodeString:         "green",
+		CodeNumber:         "aqua",
+		CodeComment:        "gray",
+	},
+	"light": {
+		Name:               "light",
+		Background:         "white",
+		ContrastBackground: "lightgray",
+		Text:               "black",
+		SecondaryText:      "navy",
+		Border:             "black",
+		Title:              "black",
+		Muted:              "dimgray",
+		Accent:             "darkblue",
+		Error:              "firebrick",
+		Quote:              "#7a4b4e",
+		Reaction:           "#6b5d00",
+		Unread:             "firebrick",
+		DefaultUser:        "darkgoldenrod",
+		OwnMention:         "white:darkblue",
+		InputLabel:         "darkgreen",
+		InputBackground:    "lavender",
+		InputText:          "black",
+		CommandBackground:  "gainsboro",
+		QuoteInput:         "saddlebrown",
+		ReactionInput:      "darkgreen",
+		SettingInput:       "darkblue",
+		Code:               "sienna",
+		CodeBorder:         "gray",
+		CodeKeyword:        "darkblue",
+		CodeString:         "darkgreen",
+		CodeNumber:         "teal",
+		CodeComment:        "dimgray",
+	},
+	"high-contrast": {
+		Name:               "high-contrast",
+		Background:         "black",
+		ContrastBackground: "navy",
+		Text:               "white",
+		SecondaryText:      "yellow",
+		Border:             "white",
+		Title:              "yellow",
+		Muted:              "silver",
+		Accent:             "yellow",
+		Error:              "red",
+		Quote:              "fuchsia",
+		Reaction:           "yellow",
+		Unread:             "red",
+		DefaultUser:        "yellow",
+		OwnMention:         "black:yellow",
+		InputLabel:         "yellow",
+		InputBackground:    "navy",
+		InputText:          "white",
+		CommandBackground:  "black",
+		QuoteInput:         "aqua",
+		ReactionInput:      "lime",
+		SettingInput:       "yellow",
+		Code:               "aqua",
+		CodeBorder:         "white",
+		CodeKeyword:        "yellow",
+		CodeString:         "lime",
+		CodeNumber:         "aqua",
+		CodeComment:        "silver",
+	},
+	"16-color": {
+		Name:               "16-color",
+		Background:         "black",
+		ContrastBackground: "navy",
+		Text:               "silver",
+		SecondaryText:      "yellow",
+		Border:             "silver",
+		Title:              "white",
+		Muted:              "gray",
+		Accent:             "yellow",
+		Error:              "red",
+		Quote:              "purple",
+		Reaction:           "olive",
+		Unread:             "red",
+		DefaultUser:        "yellow",
+		OwnMention:         "black:yellow",
+		InputLabel:         "lime",
+		InputBackground:    "navy",
+		InputText:          "white",
+		CommandBackground:  "teal",
+		QuoteInput:         "yellow",
+		ReactionInput:      "lime",
+		SettingInput:       "white",
+		Code:               "olive",
+		CodeBorder:         "gray",
+		CodeKeyword:        "yellow",
+		CodeString:         "green",
+		CodeNumber:         "aqua",
+		CodeComment:        "gray",
+		Colors:             16,
+	},
+}
+
+var (
+	// currentTheme is the theme in use, set before the widgets are created
+	currentTheme = builtinThemes[defaultThemeName]
+
+	downgradeMutex sync.Mutex
+	downgradeCache = make(map[string]string)
+)
+
+// getThemeDir returns the directory custom themes are loaded from.
+func getThemeDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".localchat", "themes"), nil
+}
+
+// loadTheme returns the built-in theme with the given name, or the custom theme of the file
+// <name>.json in dir. Colors missing in a custom theme are taken from the dark theme.
+func loadTheme(name string, dir string) (theme, error) {
+	if name == "" {
+		name = defaultThemeName
+	}
+	if builtin, ok := builtinThemes[strings.ToLower(name)]; ok {
+		return builtin, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, filepath.Base(name)+".json"))
+	if err != nil {
+		return currentTheme, fmt.Errorf("unknown theme %s: %w", name, err)
+	}
+
+	custom := builtinThemes[defaultThemeName]
+	custom.Name = name
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return currentTheme, fmt.Errorf("invalid theme %s: %w", name, err)
+	}
+
+	return custom, nil
+}
+
+// loadConfiguredTheme loads the theme set with LOCALCHAT_THEME, falling back to the dark theme.
+func loadConfiguredTheme() theme {
+	themeDir, err := getThemeDir()
+	if err != nil {
+		slog.Warn("retrieving home path failed", "err", err)
+	}
+
+	loaded, err := loadTheme(getEnvTheme(), themeDir)
+	if err != nil {
+		slog.Warn("loading theme failed", "err", err)
+	}
+	return loaded
+}
+
+// applyTheme makes the theme the current one and sets the default colors of all tview widgets.
+// It has to be called before the widgets are created.
+func applyTheme(t theme) {
+	currentTheme = t
+
+	downgradeMutex.Lock()
+	downgradeCache = make(map[string]string)
+	downgradeMutex.Unlock()
+
+	tview.Styles.PrimitiveBackgroundColor = themeColor(t.Background)
+	tview.Styles.ContrastBackgroundColor = themeColor(t.ContrastBackground)
+	tview.Styles.MoreContrastBackgroundColor = themeColor(t.CommandBackground)
+	tview.Styles.BorderColor = themeColor(t.Border)
+	tview.Styles.TitleColor = themeColor(t.Title)
+	tview.Styles.GraphicsColor = themeColor(t.Border)
+	tview.Styles.PrimaryTextColor = themeColor(t.Text)
+	tview.Styles.SecondaryTextColor = themeColor(t.SecondaryText)
+	tview.Styles.TertiaryTextColor = themeColor(t.Muted)
+	tview.Styles.InverseTextColor = themeColor(t.Background)
+	tview.Styles.ContrastSecondaryTextColor = themeColor(t.Accent)
+}
+
+// themeColor returns the tcell color of a theme color, downgraded to the colors of the terminal.
+func themeColor(color string) tcell.Color {
+	return tcell.GetColor(downgradeColor(color, getColorCount()))
+}
+
+// colorTag returns the tview tag setting the foreground color, e.g. "[gray]".
+func colorTag(color string) string {
+	return "[" + color + "]"
+}
+
+// getColorCount returns the number of colors used: the palette size of the theme if it has one,
+// otherwise 1<<24 for terminals announcing truecolor support and 256 for all others.
+func getColorCount() int {
+	if currentTheme.Colors > 0 {
+		return currentTheme.Colors
+	}
+	if os.Getenv("TCELL_TRUECOLOR") == "disable" {
+		return 256
+	}
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return 1 << 24
+	}
+	return 256
+}
+
+// downgradeColor returns the color of a palette with the given number of colors closest to the
+// given color. Colors of the palette and unknown colors are returned unchanged.
+func downgradeColor(color string, colors int) string {
+	if colors >= 1<<24 {
+		return color
+	}
+
+	c := tcell.GetColor(strings.ToLower(color))
+	if c == tcell.ColorDefault || (!c.IsRGB() && int(c-tcell.ColorValid) < colors) {
+		return color
+	}
+
+	key := fmt.Sprintf("%s/%d", color, colors)
+	downgradeMutex.Lock()
+	defer downgradeMutex.Unlock()
+
+	if downgraded, exists := downgradeCache[key]; exists {
+		return downgraded
+	}
+
+	palette := make([]tcell.Color, 0, min(colors, 256))
+	for i := 0; i < min(colors, 256); i++ {
+		palette = append(palette, tcell.PaletteColor(i))
+	}
+	r, g, b := c.RGB()
+	downgraded := tcell.FindColor(tcell.NewRGBColor(r, g, b), palette).Name(true)
+	downgradeCache[key] = downgraded
+
+	return downgraded
+}
+
+// getDisplayColor returns the color of the client as shown in the chat. Colors that are hard to
+// read on the background of the theme are adjusted, then downgraded to the colors of the theme or
+// the terminal. If the downgraded color is hard to read again, a readable color of the palette is
+// used. The plain mode uses the default color of the terminal.
+func getDisplayColor(clientID string) string {
+	if plainMode {
+		return "-"
+	}
+	colors := getColorCount()
+	color := downgradeColor(ensureContrast(getClientColor(clientID), currentTheme.Background), colors)
+	if colors < 1<<24 {
+		color = ensurePaletteContrast(color, currentTheme.Background, colors)
+	}
+	return color
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltinThemesHaveValidColors(t *testing.T) {
+	for name, builtin := range builtinThemes {
+		assert.Equal(t, name, builtin.Name)
+
+		value := reflect.ValueOf(builtin)
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.Type.Kind() != reflect.String || field.Name == "Name" {
+				continue
+			}
+			for _, color := range strings.Split(value.Field(i).String(), ":") {
+				assert.NotEqual(t, tcell.ColorDefault, tcell.GetColor(color), "%s: %s", name, field.Name)
+				if builtin.Colors > 0 {
+					assert.Equal(t, color, downgradeColor(color, builtin.Colors), "%s: %s", name, field.Name)
+				}
+			}
+		}
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "mine.json"), []byte(`{"quote": "#112233", "colors": 16}`), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{`), 0o600))
+
+	loaded, err := loadTheme("", dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "dark", loaded.Name)
+
+	loaded, err = loadTheme("Light", dir)
+	assert.NoError(t, err)
+	assert.Equal(t, builtinThemes["light"], loaded)
+
+	loaded, err = loadTheme("mine", dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "mine", loaded.Name)
+	assert.Equal(t, "#112233", loaded.Quote)
+	assert.Equal(t, 16, loaded.Colors)
+	// missing colors are taken from the dark theme
+	assert.Equal(t, builtinThemes["dark"].Reaction, loaded.Reaction)
+
+	_, err = loadTheme("broken", dir)
+	assert.Error(t, err)
+
+	_, err = loadTheme("unknown", dir)
+	assert.Error(t, err)
+}
+
+func TestDowngradeColor(t *testing.T) {
+	tests := []struct {
+		name   string
+		color  string
+		colors int
+		want   tcell.Color
+	}{
+		{name: "truecolor keeps hex colors", color: "#123456", colors: 1 << 24, want: tcell.NewHexColor(0x123456)},
+		{name: "red to the 16 color palette", color: "#fe0101", colors: 16, want: tcell.ColorRed},
+		{name: "named color to the 16 color palette", color: "darkblue", colors: 16, want: tcell.ColorNavy},
+		{name: "palette colors stay", color: "olive", colors: 16, want: tcell.ColorOlive},
+		{name: "hex color to the 256 color palette", color: "#ff0000", colors: 256, want: tcell.ColorRed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want.Hex(), tcell.GetColor(downgradeColor(tt.color, tt.colors)).Hex())
+		})
+	}
+
+	assert.Equal(t, "unknown", downgradeColor("unknown", 16))
+}
+
+func TestGetColorCount(t *testing.T) {
+	originalTheme := currentTheme
+	t.Cleanup(func() { currentTheme = originalTheme })
+	currentTheme = builtinThemes["dark"]
+
+	t.Setenv("TCELL_TRUECOLOR", "")
+	t.Setenv("COLORTERM", "truecolor")
+	assert.Equal(t, 1<<24, getColorCount())
+
+	t.Setenv("TCELL_TRUECOLOR", "disable")
+	assert.Equal(t, 256, getColorCount())
+
+	t.Setenv("TCELL_TRUECOLOR", "")
+	t.Setenv("COLORTERM", "")
+	assert.Equal(t, 256, getColorCount())
+
+	currentTheme = builtinThemes["16-color"]
+	t.Setenv("COLORTERM", "truecolor")
+	assert.Equal(t, 16, getColorCount())
+}
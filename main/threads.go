@@ -0,0 +1,223 @@
+// main package
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	threadPageName = "thread"
+	reply          = "   Reply: "
+)
+
+// currentThread is the MessageDbID of the parent of the open thread pane, empty if no thread is open.
+// threadView shows the messages of the thread. Both are only accessed from the UI goroutine, which
+// also handles the payloads of the server, see connection.
+var (
+	currentThread string
+	threadView    *tview.TextView
+)
+
+// newReplyMessagePayload builds the payload of a message of this client replying to the thread of
+// the given message. Replies to replies belong to the same thread, threads are never nested.
+func newReplyMessagePayload(parent messagePayload, message string) messagePayload {
+	payload := newMessagePayload(message)
+	payload.MessageType.ParentMessageDbID = getThreadID(parent)
+	return payload
+}
+
+// getThreadID returns the MessageDbID of the parent of the thread the message belongs to, which is
+// the message itself if it is not a reply.
+func getThreadID(payload messagePayload) string {
+	if payload.MessageType.ParentMessageDbID != "" {
+		return payload.MessageType.ParentMessageDbID
+	}
+	return payload.MessageType.MessageDbID
+}
+
+// getThreadParentIndex returns the index of the loaded parent of a reply. It returns -1 if the
+// message is no reply or its parent is not loaded; such replies are shown in the main view.
+func getThreadParentIndex(messages []messagePayload, payload messagePayload) int {
+	if payload.MessageType.ParentMessageDbID == "" {
+		return -1
+	}
+	for i, message := range messages {
+		if message.MessageType.MessageDbID == payload.MessageType.ParentMessageDbID {
+			return i
+		}
+	}
+	return -1
+}
+
+// countReplies returns the number of replies to the message with the given MessageDbID.
+func countReplies(messages []messagePayload, parentDbID string) int {
+	var count int
+	for _, message := range messages {
+		if message.MessageType.ParentMessageDbID == parentDbID {
+			count++
+		}
+	}
+	return count
+}
+
+// getThreadIndices returns the indices of the parent and all replies of the thread, in order.
+func getThreadIndices(messages []messagePayload, threadID string) []int {
+	var indices []int
+	for i, message := range messages {
+		if message.MessageType.MessageDbID == threadID || message.MessageType.ParentMessageDbID == threadID {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// visibleMessageIndices returns the indices of the messages shown in the main view, i.e. all
+// messages except replies to loaded parents.
+func visibleMessageIndices(messages []messagePayload) []int {
+	var indices []int
+	for i, message := range messages {
+		if getThreadParentIndex(messages, message) < 0 {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// moveVisibleSelection works like moveSelection, but skips the messages hidden in threads.
+func moveVisibleSelection(selected int, step int, messages []messagePayload) int {
+	visible := visibleMessageIndices(messages)
+
+	position := -1
+	for i, index := range visible {
+		if index == selected {
+			position = i
+		}
+	}
+
+	position = moveSelection(position, step, len(visible))
+	if position < 0 {
+		return -1
+	}
+	return visible[position]
+}
+
+// toVisibleIndices maps the indices of replies to those of their parents, so they can be highlighted
+// in the main view. The result is sorted, every parent is included once even if several of its
+// replies are in indices.
+func toVisibleIndices(messages []messagePayload, indices []int) []int {
+	seen := make(map[int]bool, len(indices))
+	var visible []int
+	for _, index := range indices {
+		if parentIndex := getThreadParentIndex(messages, messages[index]); parentIndex >= 0 {
+			index = parentIndex
+		}
+		if seen[index] {
+			continue
+		}
+		seen[index] = true
+		visible = append(visible, index)
+	}
+	slices.Sort(visible)
+	return visible
+}
+
+// formatReplyCount returns the line shown below a parent in the main view, e.g. "└ 3 replies".
+func formatReplyCount(count int) string {
+	switch count {
+	case 0:
+		return ""
+	case 1:
+		return "\n" + margin + colorTag(currentTheme.Muted) + getMarkers().replies + "1 reply[-]"
+	default:
+		return fmt.Sprintf("\n%s%s%s%d replies[-]", margin, colorTag(currentTheme.Muted), getMarkers().replies, count)
+	}
+}
+
+// isReplyToOwnMessage reports whether the message of another client replies to a thread started by
+// this client.
+func isReplyToOwnMessage(messages []messagePayload, payload messagePayload) bool {
+	if payload.ClientType.ClientDbID == envVars.ID {
+		return false
+	}
+	parentIndex := getThreadParentIndex(messages, payload)
+	return parentIndex >= 0 && messages[parentIndex].ClientType.ClientDbID == envVars.ID
+}
+
+// showThread opens the thread pane of the message with the given index. The pane lists the parent
+// and all replies, Enter sends the typed reply and Escape closes the pane.
+func (app *app) showThread(index int) {
+	messages := getMessagesFromCache()
+	if index < 0 || index >= len(messages) {
+		return
+	}
+	currentThread = getThreadID(messages[index])
+
+	threadView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetWordWrap(true)
+	renderThread()
+
+	replyField := tview.NewInputField().
+		SetLabel(reply).
+		SetLabelColor(themeColor(currentTheme.Accent)).
+		SetFieldBackgroundColor(themeColor(currentTheme.CommandBackground))
+	replyField.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			text := expandShortcodes(replyField.GetText())
+			if strings.TrimSpace(text) == "" {
+				return
+			}
+			if err := app.sendReply(currentThread, text); err != nil {
+				showInputHint(err.Error())
+				return
+			}
+			replyField.SetText("")
+		case tcell.KeyEscape:
+			currentThread = ""
+			threadView = nil
+			app.hideOverlay(threadPageName)
+		}
+	})
+
+	pane := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(threadView, 0, 1, false).
+		AddItem(replyField, 1, 0, true)
+	pane.SetBorder(true).SetTitle(" thread - Enter replies, Escape closes ")
+
+	app.showOverlay(threadPageName, pane)
+	app.ui.SetFocus(replyField)
+}
+
+// renderThread writes the messages of the open thread into the thread pane.
+func renderThread() {
+	if threadView == nil || currentThread == "" {
+		return
+	}
+
+	threadView.Clear()
+	messages := getMessagesFromCache()
+	for _, index := range getThreadIndices(messages, currentThread) {
+		if _, err := fmt.Fprintf(threadView, "%s\n", formatMessage(index, &messages[index])); err != nil {
+			slog.Error("writing to threadView failed", "err", err)
+		}
+	}
+	threadView.ScrollToEnd()
+}
+
+// sendReply sends a reply to the thread with the given parent MessageDbID.
+func (app *app) sendReply(threadID string, text string) error {
+	parent, ok := getMessageByDbIDFromCache(threadID)
+	if !ok {
+		return errors.New("the message of the thread is not loaded")
+	}
+	return app.sendMessagePayload(newReplyMessagePayload(parent, text))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestThread() []messagePayload {
+	parent := newTestMessagePayload("me", "parent")
+	other := newTestMessagePayload("a", "other")
+	firstReply := newReplyMessagePayload(parent, "first reply")
+	firstReply.ClientType.ClientDbID = "a"
+	secondReply := newReplyMessagePayload(firstReply, "second reply")
+	orphan := newTestMessagePayload("a", "orphan")
+	orphan.MessageType.ParentMessageDbID = "unknown"
+
+	return []messagePayload{parent, other, firstReply, secondReply, orphan}
+}
+
+func TestNewReplyMessagePayload(t *testing.T) {
+	messages := newTestThread()
+
+	assert.Equal(t, messages[0].MessageType.MessageDbID, messages[2].MessageType.ParentMessageDbID)
+	// replies to replies belong to the same thread
+	assert.Equal(t, messages[0].MessageType.MessageDbID, messages[3].MessageType.ParentMessageDbID)
+	assert.Equal(t, envVars.ID, messages[3].ClientType.ClientDbID)
+}
+
+func TestThreadIndices(t *testing.T) {
+	messages := newTestThread()
+	parentID := messages[0].MessageType.MessageDbID
+
+	assert.Equal(t, 2, countReplies(messages, parentID))
+	assert.Equal(t, 0, countReplies(messages, messages[1].MessageType.MessageDbID))
+	assert.Equal(t, []int{0, 2, 3}, getThreadIndices(messages, parentID))
+	assert.Equal(t, 0, getThreadParentIndex(messages, messages[3]))
+	assert.Equal(t, -1, getThreadParentIndex(messages, messages[4]))
+	// replies to parents which are not loaded stay visible
+	assert.Equal(t, []int{0, 1, 4}, visibleMessageIndices(messages))
+	assert.Equal(t, []int{0, 1, 4}, toVisibleIndices(messages, []int{0, 2, 3, 1, 4}))
+	// replies of one thread mixed with other messages, e.g. search hits
+	assert.Equal(t, []int{0, 1, 4}, toVisibleIndices(messages, []int{4, 2, 1, 3}))
+}
+
+func TestMoveVisibleSelection(t *testing.T) {
+	messages := newTestThread()
+
+	tests := []struct {
+		name     string
+		selected int
+		step     int
+		want     int
+	}{
+		{name: "no selection selects the newest visible message", selected: -1, step: 0, want: 4},
+		{name: "replies are skipped", selected: 4, step: -1, want: 1},
+		{name: "first message", selected: 1, step: -1, want: 0},
+		{name: "limited to the first message", selected: 0, step: -1, want: 0},
+		{name: "end", selected: 0, step: len(messages), want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, moveVisibleSelection(tt.selected, tt.step, messages))
+		})
+	}
+
+	assert.Equal(t, -1, moveVisibleSelection(-1, 0, nil))
+}
+
+func TestFormatReplyCount(t *testing.T) {
+	assert.Equal(t, "", formatReplyCount(0))
+	assert.Equal(t, "\n"+margin+"[gray]└ 1 reply[-]", formatReplyCount(1))
+	assert.Equal(t, "\n"+margin+"[gray]└ 3 replies[-]", formatReplyCount(3))
+}
+
+func TestIsReplyToOwnMessage(t *testing.T) {
+	originalID := envVars.ID
+	envVars.ID = "me"
+	t.Cleanup(func() { envVars.ID = originalID })
+
+	messages := newTestThread()
+
+	assert.True(t, isReplyToOwnMessage(messages, messages[2]))
+	assert.False(t, isReplyToOwnMessage(messages, messages[3]), "own replies")
+	assert.False(t, isReplyToOwnMessage(messages, messages[1]), "no reply")
+	assert.False(t, isReplyToOwnMessage(messages, messages[4]), "parent not loaded")
+}
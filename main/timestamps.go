@@ -0,0 +1,184 @@
+// main package
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// legacyTimestampLayout is the layout of MessageDate and MessageTime, used by older clients
+	legacyTimestampLayout = "2006-01-02 15:04"
+	dateLayout            = "2006-01-02"
+
+	timeFormat24h      = "24h"
+	timeFormat12h      = "12h"
+	timeFormatRelative = "relative"
+
+	// relativeTimeInterval is how often relative times like "5m ago" are rendered again
+	relativeTimeInterval = time.Minute
+)
+
+// lastRenderedDay is the day of the last message written into the chat view, a day separator is
+// drawn before the first message of every day. It is only accessed from the UI goroutine.
+var lastRenderedDay string
+
+// newTimestamps returns the RFC 3339 UTC timestamp of the given time, together with the local date
+// and time for clients which only know MessageDate and MessageTime.
+func newTimestamps(now time.Time) (timestamp string, date string, clock string) {
+	return now.UTC().Format(time.RFC3339), now.Format(dateLayout), now.Format("15:04")
+}
+
+// parseTimestamp returns the time of the RFC 3339 timestamp. Without timestamp, as sent by older
+// clients, date and clock are parsed in the local time zone. It returns false if neither can be parsed.
+func parseTimestamp(timestamp string, date string, clock string) (time.Time, bool) {
+	if timestamp != "" {
+		if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
+			return t, true
+		}
+	}
+	if t, err := time.ParseInLocation(legacyTimestampLayout, date+" "+clock, time.Local); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// getMessageTimestamp returns the time the message was sent.
+func getMessageTimestamp(payload messagePayload) (time.Time, bool) {
+	return parseTimestamp(payload.MessageType.Timestamp, payload.MessageType.MessageDate, payload.MessageType.MessageTime)
+}
+
+// getMessageDay returns the local date of the message, e.g. "2024-05-01".
+func getMessageDay(payload messagePayload) string {
+	if t, ok := getMessageTimestamp(payload); ok {
+		return t.Local().Format(dateLayout)
+	}
+	return payload.MessageType.MessageDate
+}
+
+// formatClock formats the time of a message in the given format: "24h" like 15:04, "12h" like
+// 3:04 PM or "relative" like "5m ago", which falls back to the weekday or date for older messages.
+func formatClock(t time.Time, format string, now time.Time) string {
+	t, now = t.Local(), now.Local()
+
+	switch format {
+	case timeFormat12h:
+		return t.Format("3:04 PM")
+	case timeFormatRelative:
+		age := now.Sub(t)
+		switch {
+		case age < time.Minute:
+			return "now"
+		case age < time.Hour:
+			return fmt.Sprintf("%dm ago", int(age.Minutes()))
+		case isSameDay(t, now):
+			return fmt.Sprintf("%dh ago", int(age.Hours()))
+		case isSameDay(t, now.AddDate(0, 0, -1)):
+			return "yesterday " + t.Format("15:04")
+		case age < 7*24*time.Hour:
+			return t.Format("Mon 15:04")
+		default:
+			return t.Format(dateLayout)
+		}
+	default:
+		return t.Format("15:04")
+	}
+}
+
+// formatMessageClock formats the time of the message in the configured format. Messages without
+// parsable time show their MessageTime as is.
+func formatMessageClock(payload messagePayload) string {
+	if t, ok := getMessageTimestamp(payload); ok {
+		return formatClock(t, getEnvTimeFormat(), time.Now())
+	}
+	return payload.MessageType.MessageTime
+}
+
+// formatQuoteClock formats the time of the quoted message in the configured format.
+func formatQuoteClock(quote quoteType) string {
+	if t, ok := parseTimestamp(quote.QuoteTimestamp, quote.QuoteDate, quote.QuoteTime); ok {
+		return formatClock(t, getEnvTimeFormat(), time.Now())
+	}
+	return quote.QuoteTime
+}
+
+// isSameDay reports whether both times are on the same calendar day.
+func isSameDay(a time.Time, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// formatDay returns the label of a day separator, e.g. "Today", "Yesterday" or "Wednesday, 1 May 2024".
+func formatDay(day string, now time.Time) string {
+	t, err := time.ParseInLocation(dateLayout, day, time.Local)
+	if err != nil {
+		return day
+	}
+
+	switch {
+	case isSameDay(t, now):
+		return "Today"
+	case isSameDay(t, now.AddDate(0, 0, -1)):
+		return "Yesterday"
+	default:
+		return t.Format("Monday, 2 January 2006")
+	}
+}
+
+// writeDaySeparator draws a separator into the chat view if the message is the first of its day.
+func writeDaySeparator(payload messagePayload) {
+	day := getMessageDay(payload)
+	if day == "" || day == lastRenderedDay {
+		return
+	}
+	lastRenderedDay = day
+
+	markers := getMarkers()
+	if _, err := fmt.Fprintf(chatView, "%s%s%s%s%s[-]\n", margin, colorTag(currentTheme.Muted), markers.dayStart,
+		formatDay(day, time.Now()), markers.dayEnd); err != nil {
+		slog.Error("writing to chatView failed", "err", err)
+	}
+}
+
+// refreshRelativeTimes renders the messages again every interval while the time format is relative,
+// so "5m ago" does not go stale. The messages are rendered in place, keeping the scroll position;
+// after midnight the whole chat view is rendered again for the day separators. It returns once the
+// app quits.
+func (app *app) refreshRelativeTimes(interval time.Duration) {
+	if getEnvTimeFormat() != timeFormatRelative {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	today := time.Now().Format(dateLayout)
+	for {
+		select {
+		case <-app.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if day := now.Format(dateLayout); day != today {
+				today = day
+				app.ui.QueueUpdateDraw(app.refreshMessages)
+				continue
+			}
+			app.ui.QueueUpdateDraw(app.redrawAllMessages)
+		}
+	}
+}
+
+// normalizeTimeFormat returns one of the supported time formats, 24h by default.
+func normalizeTimeFormat(format string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case timeFormat12h:
+		return timeFormat12h
+	case timeFormatRelative:
+		return timeFormatRelative
+	default:
+		return timeFormat24h
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setTestLocation(t *testing.T) {
+	t.Helper()
+	original := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+	t.Cleanup(func() { time.Local = original })
+}
+
+func TestNewTimestamps(t *testing.T) {
+	setTestLocation(t)
+
+	timestamp, date, clock := newTimestamps(time.Date(2024, 5, 1, 23, 30, 0, 0, time.Local))
+	assert.Equal(t, "2024-05-01T21:30:00Z", timestamp)
+	assert.Equal(t, "2024-05-01", date)
+	assert.Equal(t, "23:30", clock)
+}
+
+func TestParseTimestamp(t *testing.T) {
+	setTestLocation(t)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		date      string
+		clock     string
+		want      time.Time
+		ok        bool
+	}{
+		{
+			name:      "rfc 3339",
+			timestamp: "2024-05-01T21:30:00Z",
+			date:      "2024-05-01",
+			clock:     "23:30",
+			want:      time.Date(2024, 5, 1, 21, 30, 0, 0, time.UTC),
+			ok:        true,
+		},
+		{
+			name:  "legacy fields are local time",
+			date:  "2024-05-01",
+			clock: "23:30",
+			want:  time.Date(2024, 5, 1, 21, 30, 0, 0, time.UTC),
+			ok:    true,
+		},
+		{
+			name:      "invalid timestamp falls back to the legacy fields",
+			timestamp: "yesterday",
+			date:      "2024-05-01",
+			clock:     "23:30",
+			want:      time.Date(2024, 5, 1, 21, 30, 0, 0, time.UTC),
+			ok:        true,
+		},
+		{name: "time only", clock: "23:30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseTimestamp(tt.timestamp, tt.date, tt.clock)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.True(t, tt.want.Equal(got), "got %v", got)
+			}
+		})
+	}
+}
+
+func TestGetMessageDay(t *testing.T) {
+	setTestLocation(t)
+
+	payload := newTestMessagePayload("a", "late")
+	payload.MessageType.Timestamp = "2024-05-01T22:30:00Z"
+	payload.MessageType.MessageDate = "2024-05-01"
+	// the day of the local time zone
+	assert.Equal(t, "2024-05-02", getMessageDay(payload))
+
+	payload.MessageType.Timestamp = ""
+	payload.MessageType.MessageTime = ""
+	assert.Equal(t, "2024-05-01", getMessageDay(payload))
+}
+
+func TestFormatClock(t *testing.T) {
+	setTestLocation(t)
+	now := time.Date(2024, 5, 8, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name   string
+		t      time.Time
+		format string
+		want   string
+	}{
+		{name: "24h", t: time.Date(2024, 5, 8, 9, 5, 0, 0, time.Local), format: timeFormat24h, want: "09:05"},
+		{name: "12h", t: time.Date(2024, 5, 8, 21, 5, 0, 0, time.Local), format: timeFormat12h, want: "9:05 PM"},
+		{name: "utc is shown in local time", t: time.Date(2024, 5, 8, 9, 5, 0, 0, time.UTC), format: timeFormat24h, want: "11:05"},
+		{name: "now", t: now.Add(-30 * time.Second), format: timeFormatRelative, want: "now"},
+		{name: "minutes", t: now.Add(-5 * time.Minute), format: timeFormatRelative, want: "5m ago"},
+		{name: "hours", t: now.Add(-3 * time.Hour), format: timeFormatRelative, want: "3h ago"},
+		{name: "yesterday", t: time.Date(2024, 5, 7, 18, 15, 0, 0, time.Local), format: timeFormatRelative, want: "yesterday 18:15"},
+		{name: "weekday", t: time.Date(2024, 5, 4, 18, 15, 0, 0, time.Local), format: timeFormatRelative, want: "Sat 18:15"},
+		{name: "date", t: time.Date(2024, 4, 1, 18, 15, 0, 0, time.Local), format: timeFormatRelative, want: "2024-04-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, formatClock(tt.t, tt.format, now))
+		})
+	}
+}
+
+func TestFormatDay(t *testing.T) {
+	setTestLocation(t)
+	now := time.Date(2024, 5, 8, 12, 0, 0, 0, time.Local)
+
+	assert.Equal(t, "Today", formatDay("2024-05-08", now))
+	assert.Equal(t, "Yesterday", formatDay("2024-05-07", now))
+	assert.Equal(t, "Wednesday, 1 May 2024", formatDay("2024-05-01", now))
+	assert.Equal(t, "unknown", formatDay("unknown", now))
+}
+
+func TestNormalizeTimeFormat(t *testing.T) {
+	assert.Equal(t, timeFormat24h, normalizeTimeFormat(""))
+	assert.Equal(t, timeFormat12h, normalizeTimeFormat("12H"))
+	assert.Equal(t, timeFormatRelative, normalizeTimeFormat(" relative "))
+	assert.Equal(t, timeFormat24h, normalizeTimeFormat("unknown"))
+}
+
+func TestRefreshRelativeTimes_StopsWhenQuitting(t *testing.T) {
+	original := envVars.TimeFormat
+	envVars.TimeFormat = timeFormatRelative
+	t.Cleanup(func() { envVars.TimeFormat = original })
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	quitting := &app{ctx: ctx, cancel: cancel}
+
+	done := make(chan struct{})
+	go func() {
+		quitting.refreshRelativeTimes(time.Hour)
+		close(done)
+	}()
+	quitting.quit(errQuit)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refreshing relative times did not stop")
+	}
+}
@@ -0,0 +1,303 @@
+// main package
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+var (
+	unreadMutex        sync.Mutex
+	lastReadMessageID  string
+	unreadCount        int
+	unreadDividerCount int
+	unreadRegionID     string
+
+	// titleUnreadCount is the unread count shown in the terminal title, -1 before the title is set.
+	// It is only accessed from the UI goroutine.
+	titleUnreadCount = -1
+
+	// chatFollowsEnd reports whether the chat view follows its end, it is false once the user
+	// scrolled up. chatEndRow is the scroll offset of the end, learned after draws while the view
+	// follows it. Both are only accessed from the UI goroutine.
+	chatFollowsEnd = true
+	chatEndRow     int
+)
+
+// getLastReadFilePath returns the path of the file the last read message id is persisted in.
+func getLastReadFilePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".localchat", "unread", "lastread.txt"), nil
+}
+
+// loadLastReadMessageID reads the last read message id from disk.
+// It returns an empty string if no message was marked as read yet.
+func loadLastReadMessageID() string {
+	lastReadFilePath, err := getLastReadFilePath()
+	if err != nil {
+		slog.Warn("retrieving home path failed", "err", err)
+		return ""
+	}
+
+	id, err := os.ReadFile(lastReadFilePath)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(id))
+}
+
+// loadUnreadState reads the last read message id from disk, it is called once at startup.
+func loadUnreadState() {
+	id := loadLastReadMessageID()
+
+	unreadMutex.Lock()
+	defer unreadMutex.Unlock()
+	lastReadMessageID = id
+}
+
+// saveLastReadMessageID persists the last read message id.
+func saveLastReadMessageID(id string) error {
+	lastReadFilePath, err := getLastReadFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(lastReadFilePath), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(lastReadFilePath, []byte(id), 0o600)
+}
+
+// findFirstUnread returns the index of the first unread message and the number of unread messages.
+// Messages after the last read message are unread, except those sent by ownID. If the last read
+// message is not part of the given messages, all of them are considered unread. Nothing is unread
+// if there is no last read message yet. The index is -1 if there are no unread messages.
+func findFirstUnread(messages []messagePayload, lastReadID string, ownID string) (firstUnread int, count int) {
+	firstUnread = -1
+	if lastReadID == "" {
+		return firstUnread, 0
+	}
+
+	start := 0
+	for i, message := range messages {
+		if message.MessageType.MessageDbID == lastReadID {
+			start = i + 1
+			break
+		}
+	}
+
+	for i := start; i < len(messages); i++ {
+		if messages[i].ClientType.ClientDbID == ownID {
+			continue
+		}
+		if firstUnread == -1 {
+			firstUnread = i
+		}
+		count++
+	}
+
+	return firstUnread, count
+}
+
+// writeUnreadDivider draws the "new messages" divider into the chat view, wrapped in its own region
+// to be able to jump to it. The divider of the previous unread messages is removed.
+func writeUnreadDivider() {
+	unreadMutex.Lock()
+	previousRegionID := unreadRegionID
+	unreadDividerCount++
+	unreadRegionID = fmt.Sprintf("unread-%d", unreadDividerCount)
+	regionID := unreadRegionID
+	unreadMutex.Unlock()
+
+	if previousRegionID != "" {
+		chatView.SetText(removeRegion(chatView.GetText(false), previousRegionID))
+	}
+
+	if _, err := fmt.Fprintf(chatView, "[\"%s\"]%s%s%s[-][\"\"]\n", regionID, colorTag(currentTheme.Unread), margin, getMarkers().unread); err != nil {
+		slog.Error("writing to chatView failed", "err", err)
+	}
+}
+
+// removeRegion removes the line of the region with the given id from text, see replaceRegions.
+func removeRegion(text string, regionID string) string {
+	start := strings.Index(text, `["`+regionID+`"]`)
+	if start < 0 {
+		return text
+	}
+
+	endTag := "[\"\"]\n"
+	end := strings.Index(text[start:], endTag)
+	if end < 0 {
+		return text
+	}
+	return text[:start] + text[start+end+len(endTag):]
+}
+
+// resetUnread applies the unread state of a freshly loaded message list.
+// It returns the index of the first unread message, or -1.
+func resetUnread(messages []messagePayload) int {
+	unreadMutex.Lock()
+	defer unreadMutex.Unlock()
+
+	firstUnread, count := findFirstUnread(messages, lastReadMessageID, envVars.ID)
+	unreadCount = count
+	unreadRegionID = ""
+
+	return firstUnread
+}
+
+// addUnreadMessage counts a new message from another client as unread.
+// It returns true if it is the first unread message, so the divider has to be drawn in front of it.
+func addUnreadMessage() bool {
+	unreadMutex.Lock()
+	defer unreadMutex.Unlock()
+
+	unreadCount++
+	return unreadCount == 1
+}
+
+// getUnreadCount returns the number of unread messages.
+func getUnreadCount() int {
+	unreadMutex.Lock()
+	defer unreadMutex.Unlock()
+
+	return unreadCount
+}
+
+// markAllAsRead marks the latest cached message as last read and resets the unread counter.
+func markAllAsRead() {
+	messages := getMessagesFromCache()
+	if len(messages) == 0 {
+		return
+	}
+
+	unreadMutex.Lock()
+	unreadCount = 0
+	lastReadID := messages[len(messages)-1].MessageType.MessageDbID
+	changed := lastReadID != lastReadMessageID
+	lastReadMessageID = lastReadID
+	unreadMutex.Unlock()
+
+	if !changed {
+		return
+	}
+	if err := saveLastReadMessageID(lastReadID); err != nil {
+		slog.Warn("saving last read message id failed", "err", err)
+	}
+}
+
+// setTerminalTitle sets the title of the terminal window using OSC 0.
+func setTerminalTitle(out io.Writer, title string) {
+	if _, err := fmt.Fprintf(out, "\x1b]0;%s\x07", sanitizeTerminalText(title)); err != nil {
+		slog.Warn("setting terminal title failed", "err", err)
+	}
+}
+
+// generateUnreadTitle returns the terminal title for the given number of unread messages.
+func generateUnreadTitle(count int) string {
+	if count == 0 {
+		return "localterm"
+	}
+	return fmt.Sprintf("localterm (%d)", count)
+}
+
+// updateUnreadIndicators shows the current unread count in the terminal title and the status bar.
+// It is called from the UI goroutine, so the title is not written during a screen update. The
+// title is only written if the count changed.
+func (app *app) updateUnreadIndicators() {
+	count := getUnreadCount()
+
+	if count != titleUnreadCount {
+		titleUnreadCount = count
+		setTerminalTitle(os.Stdout, generateUnreadTitle(count))
+	}
+
+	if statusView == nil {
+		return
+	}
+	if count == 0 {
+		statusView.SetText("")
+		return
+	}
+	hint := ""
+	if key := activeKeymap.keyFor(actionJumpUnread); key != "" {
+		hint = fmt.Sprintf(" %s(%s to jump)[-]", colorTag(currentTheme.Muted), tview.Escape(key))
+	}
+	statusView.SetText(fmt.Sprintf("%s%d unread[-]%s", colorTag(currentTheme.Unread), count, hint))
+}
+
+// trackChatEnd learns the scroll offset of the end of the chat view after the screen was drawn.
+func trackChatEnd(_ tcell.Screen) {
+	if chatFollowsEnd && chatView != nil {
+		chatEndRow, _ = chatView.GetScrollOffset()
+	}
+}
+
+// scrollChatTo scrolls the chat view to the row. Once its end is reached the view follows it again.
+func scrollChatTo(row int, column int) {
+	if row >= chatEndRow {
+		scrollChatToEnd()
+		return
+	}
+	chatView.ScrollTo(row, column)
+	chatFollowsEnd = false
+}
+
+// scrollChatToEnd scrolls the chat view to its end and follows it.
+func scrollChatToEnd() {
+	chatView.ScrollToEnd()
+	chatFollowsEnd = true
+}
+
+// isChatAtEnd reports whether the end of the chat view is visible.
+func isChatAtEnd() bool {
+	if chatFollowsEnd {
+		return true
+	}
+	row, _ := chatView.GetScrollOffset()
+	return row >= chatEndRow
+}
+
+// markVisibleAsRead marks all messages as read while the user is looking at them, that is on input
+// while the chat view has the focus or shows its end. Messages arriving while localterm is not
+// used stay unread, so they are counted in the terminal title.
+func (app *app) markVisibleAsRead() {
+	if getUnreadCount() == 0 {
+		return
+	}
+	if app.ui.GetFocus() != chatView && !isChatAtEnd() {
+		return
+	}
+
+	app.markAllAsRead()
+	app.updateUnreadIndicators()
+}
+
+// jumpToFirstUnread scrolls the chat view to the "new messages" divider and marks all messages as read.
+func (app *app) jumpToFirstUnread() {
+	unreadMutex.Lock()
+	regionID := unreadRegionID
+	unreadMutex.Unlock()
+
+	if regionID != "" {
+		chatView.Highlight(regionID).ScrollToHighlight()
+		chatFollowsEnd = false
+	}
+
+	app.markAllAsRead()
+	app.updateUnreadIndicators()
+}
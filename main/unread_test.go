@@ -0,0 +1,94 @@
+// main package
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindFirstUnread(t *testing.T) {
+	messages := []messagePayload{
+		{MessageType: messageType{MessageDbID: "a"}, ClientType: clientType{ClientDbID: "other"}},
+		{MessageType: messageType{MessageDbID: "b"}, ClientType: clientType{ClientDbID: "me"}},
+		{MessageType: messageType{MessageDbID: "c"}, ClientType: clientType{ClientDbID: "me"}},
+		{MessageType: messageType{MessageDbID: "d"}, ClientType: clientType{ClientDbID: "other"}},
+		{MessageType: messageType{MessageDbID: "e"}, ClientType: clientType{ClientDbID: "other"}},
+	}
+
+	tests := []struct {
+		name       string
+		lastReadID string
+		wantIndex  int
+		wantCount  int
+	}{
+		{name: "nothing read yet", lastReadID: "", wantIndex: -1, wantCount: 0},
+		{name: "all read", lastReadID: "e", wantIndex: -1, wantCount: 0},
+		{name: "own messages are skipped", lastReadID: "a", wantIndex: 3, wantCount: 2},
+		{name: "last message unread", lastReadID: "d", wantIndex: 4, wantCount: 1},
+		{name: "last read not loaded", lastReadID: "x", wantIndex: 0, wantCount: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, count := findFirstUnread(messages, tt.lastReadID, "me")
+			assert.Equal(t, tt.wantIndex, index)
+			assert.Equal(t, tt.wantCount, count)
+		})
+	}
+}
+
+func TestSetTerminalTitle(t *testing.T) {
+	var out bytes.Buffer
+	setTerminalTitle(&out, generateUnreadTitle(3))
+	assert.Equal(t, "\x1b]0;localterm (3)\x07", out.String())
+
+	out.Reset()
+	setTerminalTitle(&out, generateUnreadTitle(0))
+	assert.Equal(t, "\x1b]0;localterm\x07", out.String())
+}
+
+func TestWriteUnreadDividerRemovesThePreviousOne(t *testing.T) {
+	chatView = tview.NewTextView().SetDynamicColors(true).SetRegions(true)
+	t.Cleanup(func() {
+		unreadRegionID = ""
+	})
+
+	writeUnreadDivider()
+	fmt.Fprint(chatView, "[\"msg-0\"]first[\"\"]\n")
+	firstRegionID := unreadRegionID
+	writeUnreadDivider()
+	fmt.Fprint(chatView, "[\"msg-1\"]second[\"\"]\n")
+
+	text := chatView.GetText(false)
+	assert.NotContains(t, text, `["`+firstRegionID+`"]`)
+	assert.Equal(t, 1, strings.Count(text, `["unread-`))
+	assert.Less(t, strings.Index(text, "first"), strings.Index(text, `["`+unreadRegionID+`"]`))
+	assert.Less(t, strings.Index(text, `["`+unreadRegionID+`"]`), strings.Index(text, "second"))
+}
+
+func TestRemoveRegion(t *testing.T) {
+	text := "[\"msg-0\"]first[\"\"]\n[\"unread-1\"]new[\"\"]\n[\"msg-1\"]second[\"\"]\n"
+	assert.Equal(t, "[\"msg-0\"]first[\"\"]\n[\"msg-1\"]second[\"\"]\n", removeRegion(text, "unread-1"))
+	assert.Equal(t, text, removeRegion(text, "unread-2"))
+}
+
+func TestScrollChatTo(t *testing.T) {
+	chatView = tview.NewTextView()
+	t.Cleanup(func() {
+		chatFollowsEnd, chatEndRow = true, 0
+	})
+	chatFollowsEnd, chatEndRow = true, 10
+
+	scrollChatTo(9, 0)
+	assert.False(t, chatFollowsEnd)
+	assert.False(t, isChatAtEnd())
+
+	scrollChatTo(10, 0)
+	assert.True(t, chatFollowsEnd)
+	assert.True(t, isChatAtEnd())
+}